@@ -0,0 +1,391 @@
+package cors
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminConfig holds the configuration of the admin handler, inspecting and updating the live policy
+type AdminConfig struct {
+	// Path is the path the admin handler is mounted on, in front of the gateway routes
+	Path string
+	// Port starts a dedicated server for the admin handler, listening on all the interfaces
+	Port int
+	// Token is the bearer token required to access the admin handler
+	Token string
+}
+
+// adminKeys lists all the options accepted in the admin object
+var adminKeys = []string{
+	"path",
+	"port",
+	"token",
+}
+
+// parseAdminConfig returns nil if the admin handler can not be enabled safely
+func parseAdminConfig(p *parser) *AdminConfig {
+	cfg := &AdminConfig{
+		Path:  p.string("path"),
+		Port:  p.int("port"),
+		Token: p.string("token"),
+	}
+	if cfg.Path != "" && !strings.HasPrefix(cfg.Path, "/") {
+		p.fail("path", p.path("path"), cfg.Path, "admin path", errors.New(`it must start with "/"`))
+		cfg.Path = ""
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		p.fail("port", p.path("port"), cfg.Port, "admin port", fmt.Errorf("%d is out of range", cfg.Port))
+		cfg.Port = 0
+	}
+	if cfg.Path == "" && cfg.Port == 0 {
+		p.fail("path", p.path("path"), "", "admin path", errors.New("a path or a port is required"))
+		return nil
+	}
+	if cfg.Token == "" {
+		p.fail("token", p.path("token"), "", "admin token", errors.New("required to protect the admin handler"))
+		return nil
+	}
+	return cfg
+}
+
+// AdminChanges lists the entries added to or removed from the allowed origins, methods and headers
+type AdminChanges struct {
+	AllowOrigins []string `json:"allow_origins"`
+	AllowMethods []string `json:"allow_methods"`
+	AllowHeaders []string `json:"allow_headers"`
+}
+
+// AdminPatch is the body of the PATCH requests to the admin handler. The removals are applied first.
+type AdminPatch struct {
+	Add    AdminChanges `json:"add"`
+	Remove AdminChanges `json:"remove"`
+}
+
+// errInvalidPatch is wrapped by the errors of the patches that can not be applied to the policy
+var errInvalidPatch = errors.New("invalid patch")
+
+// adminHandler serves the admin API of a live policy: GET returns the effective configuration and
+// PATCH updates the allowed origins, methods and headers
+type adminHandler struct {
+	live  *LivePolicy
+	token string
+	// path is the path the handler is mounted on in front of the gateway routes, if any
+	path string
+	// server is the dedicated server of the handler, if any
+	server *http.Server
+}
+
+func (a *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cors"`)
+		writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminConfig(w, a.live.Config())
+	case http.MethodPatch:
+		var patch AdminPatch
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&patch); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+			return
+		}
+		cfg, err := a.live.Update(func(cfg Config) (Config, error) {
+			return applyAdminPatch(cfg, a.live.Policy(), patch)
+		})
+		if err != nil {
+			writeAdminError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		a.live.l.Info(logPrefix, "Policy updated by the admin handler:", patch.String())
+		writeAdminConfig(w, cfg)
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (a *adminHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// listen starts the dedicated server of the admin handler. The errors are logged, since the gateway
+// keeps running without it.
+func (a *adminHandler) listen(port int) {
+	s := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           a,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.server = s
+	go func() {
+		if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.live.l.Error(logPrefix, "The admin server stopped:", err.Error())
+		}
+	}()
+}
+
+// close stops the dedicated server of the handler, releasing its port
+func (a *adminHandler) close() error {
+	if a.server == nil {
+		return nil
+	}
+	return a.server.Close()
+}
+
+// applyAdminPatch applies the patch to the configuration of the live policy. The lists are patched
+// starting from their effective values, so adding an entry to a list left to its default keeps the
+// default entries. The patches emptying a list (which would restore its default) or changing the
+// methods or the headers in auto mode are refused, as well as the ones making the policy insecure.
+// The origins can only be patched when the allowed origins decide them (i.e. not with a validator
+// using other sources). The tenants inheriting the patched lists get the new ones.
+func applyAdminPatch(cfg Config, current *Policy, patch AdminPatch) (Config, error) {
+	effective := current.Config()
+	var err error
+	for i, o := range patch.Add.AllowOrigins {
+		if patch.Add.AllowOrigins[i], err = normalizeOriginEntry(o); err != nil {
+			return cfg, fmt.Errorf("%w: origin %q: %s", errInvalidPatch, o, err.Error())
+		}
+	}
+	for i, o := range patch.Remove.AllowOrigins {
+		if n, err := normalizeOriginEntry(o); err == nil {
+			patch.Remove.AllowOrigins[i] = n
+		}
+	}
+	if len(patch.Add.AllowOrigins) > 0 || len(patch.Remove.AllowOrigins) > 0 {
+		if current.customOrigins || cfg.OriginValidator != "" && cfg.OriginValidator != OriginValidatorStatic {
+			return cfg, fmt.Errorf("%w: the allowed origins are decided by the origin validator", errInvalidPatch)
+		}
+		cfg.AllowOrigins = patchList(effective.AllowOrigins, patch.Add.AllowOrigins, patch.Remove.AllowOrigins)
+		if len(cfg.AllowOrigins) == 0 && cfg.wildcardOrigin() {
+			return cfg, fmt.Errorf("%w: no allowed origins would be left", errInvalidPatch)
+		}
+	}
+
+	if len(patch.Add.AllowMethods) > 0 || len(patch.Remove.AllowMethods) > 0 {
+		if cfg.AutoMethods {
+			return cfg, fmt.Errorf("%w: the allowed methods are derived from the endpoints", errInvalidPatch)
+		}
+		for i, m := range patch.Add.AllowMethods {
+			patch.Add.AllowMethods[i] = strings.ToUpper(m)
+		}
+		cfg.AllowMethods = patchList(effective.AllowMethods, patch.Add.AllowMethods, patch.Remove.AllowMethods)
+		if len(cfg.AllowMethods) == 0 {
+			return cfg, fmt.Errorf("%w: no allowed methods would be left", errInvalidPatch)
+		}
+	}
+
+	if len(patch.Add.AllowHeaders) > 0 || len(patch.Remove.AllowHeaders) > 0 {
+		if cfg.AutoHeaders {
+			return cfg, fmt.Errorf("%w: the allowed headers are derived from the endpoints", errInvalidPatch)
+		}
+		cfg.AllowHeaders = patchList(effective.AllowHeaders, patch.Add.AllowHeaders, patch.Remove.AllowHeaders)
+		if len(cfg.AllowHeaders) == 0 {
+			return cfg, fmt.Errorf("%w: no allowed headers would be left", errInvalidPatch)
+		}
+	}
+
+	cfg.Tenants = inheritPatchedLists(cfg)
+
+	if cfg.AllowInsecure {
+		return cfg, nil
+	}
+	var insecure []string
+	for _, f := range cfg.Lint() {
+		if f.Severity == SeverityError {
+			insecure = append(insecure, f.Rule)
+		}
+	}
+	if len(insecure) > 0 {
+		return cfg, fmt.Errorf("%w: %s", ErrInsecureConfig, strings.Join(insecure, ", "))
+	}
+	return cfg, nil
+}
+
+// inheritPatchedLists returns a copy of the tenants with the lists patched by the admin handler
+// replaced in the ones inheriting them
+func inheritPatchedLists(cfg Config) map[string]Config {
+	if len(cfg.Tenants) == 0 {
+		return cfg.Tenants
+	}
+	tenants := make(map[string]Config, len(cfg.Tenants))
+	for host, t := range cfg.Tenants {
+		if contains(t.inherited, "allow_origins") {
+			t.AllowOrigins = cfg.AllowOrigins
+		}
+		if contains(t.inherited, "allow_methods") {
+			t.AllowMethods = cfg.AllowMethods
+		}
+		if contains(t.inherited, "allow_headers") {
+			t.AllowHeaders = cfg.AllowHeaders
+		}
+		tenants[host] = t
+	}
+	return tenants
+}
+
+// patchList returns a copy of the list without the removed entries and with the added ones not
+// already present, comparing them without regard to case
+func patchList(list, add, remove []string) []string {
+	has := func(l []string, v string) bool {
+		for _, e := range l {
+			if strings.EqualFold(e, v) {
+				return true
+			}
+		}
+		return false
+	}
+	out := make([]string, 0, len(list)+len(add))
+	for _, v := range list {
+		if !has(remove, v) {
+			out = append(out, v)
+		}
+	}
+	for _, v := range add {
+		if !has(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (p AdminPatch) String() string {
+	var parts []string
+	for _, c := range []struct {
+		op      string
+		changes AdminChanges
+	}{{"+", p.Add}, {"-", p.Remove}} {
+		for _, l := range []struct {
+			key    string
+			values []string
+		}{
+			{"allow_origins", c.changes.AllowOrigins},
+			{"allow_methods", c.changes.AllowMethods},
+			{"allow_headers", c.changes.AllowHeaders},
+		} {
+			if len(l.values) > 0 {
+				parts = append(parts, fmt.Sprintf("%s%s=%s", c.op, l.key, strings.Join(l.values, ",")))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func writeAdminError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  msg,
+		"status": status,
+	})
+}
+
+func writeAdminConfig(w http.ResponseWriter, cfg Config) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cfg.toMap())
+}
+
+// toMap renders the Config with the keys of the CORS namespace, skipping the empty values. The
+// token of the admin handler and the options of the origin validator (which may hold credentials)
+// are never included.
+func (c Config) toMap() map[string]interface{} {
+	m := map[string]interface{}{}
+	set := func(k string, v interface{}) {
+		switch v := v.(type) {
+		case []string:
+			if len(v) > 0 {
+				m[k] = v
+			}
+		case string:
+			if v != "" {
+				m[k] = v
+			}
+		case bool:
+			if v {
+				m[k] = v
+			}
+		case int:
+			if v != 0 {
+				m[k] = v
+			}
+		case time.Duration:
+			if v != 0 {
+				m[k] = v.String()
+			}
+		}
+	}
+	set("allow_origins", c.AllowOrigins)
+	set("allow_origins_regex", c.AllowOriginsRegex)
+	set("allow_origins_patterns", c.AllowOriginsPatterns)
+	set("allow_origins_file", c.AllowOriginsFile)
+	set("allow_origins_file_interval", c.AllowOriginsFileInterval)
+	if c.AutoMethods {
+		set("allow_methods", "auto")
+	} else {
+		set("allow_methods", c.AllowMethods)
+	}
+	if c.AutoHeaders {
+		set("allow_headers", "auto")
+	} else {
+		set("allow_headers", c.AllowHeaders)
+	}
+	set("always_allow_headers", c.AlwaysAllowHeaders)
+	set("expose_headers", c.ExposeHeaders)
+	set("allow_credentials", c.AllowCredentials)
+	set("allow_private_network", c.AllowPrivateNetwork)
+	set("options_passthrough", c.OptionsPassthrough)
+	set("options_success_status", c.OptionsSuccessStatus)
+	set("max_age", c.MaxAge)
+	set("debug", c.Debug)
+	set("compat", c.Compat)
+	set("strict", c.Strict)
+	set("allow_insecure", c.AllowInsecure)
+	if c.Audit != nil {
+		audit := map[string]interface{}{"output": c.Audit.Output}
+		if c.Audit.Path != "" {
+			audit["path"] = c.Audit.Path
+		}
+		if c.Audit.DedupWindow != 0 {
+			audit["dedup_window"] = c.Audit.DedupWindow.String()
+		}
+		m["audit"] = audit
+	}
+	set("enforce", c.Enforce)
+	set("enforce_status", c.EnforceStatus)
+	set("enforce_body", c.EnforceBody)
+	set("allow_private_network_origins", c.AllowPrivateNetworkOrigins)
+	set("private_network_access_name", c.PrivateNetworkAccessName)
+	set("private_network_access_id", c.PrivateNetworkAccessID)
+	set("allow_null_origin", c.AllowNullOrigin)
+	set("origin_validator", c.OriginValidator)
+	if len(c.Tenants) > 0 {
+		tenants := make(map[string]interface{}, len(c.Tenants))
+		for host, t := range c.Tenants {
+			tenants[host] = t.toMap()
+		}
+		m["tenants"] = tenants
+	}
+	set("tenant_header", c.TenantHeader)
+	set("tenant_fallback", c.TenantFallback)
+	if c.Admin != nil {
+		admin := map[string]interface{}{}
+		if c.Admin.Path != "" {
+			admin["path"] = c.Admin.Path
+		}
+		if c.Admin.Port != 0 {
+			admin["port"] = c.Admin.Port
+		}
+		m["admin"] = admin
+	}
+	return m
+}
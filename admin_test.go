@@ -0,0 +1,391 @@
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseConfig_admin(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		admin map[string]interface{}
+		err   string
+	}{
+		{
+			name:  "no token",
+			admin: map[string]interface{}{"path": "/__cors"},
+			err:   "security/cors.admin.token: invalid admin token: required to protect the admin handler",
+		},
+		{
+			name:  "no path nor port",
+			admin: map[string]interface{}{"token": "secret"},
+			err:   "security/cors.admin.path: invalid admin path: a path or a port is required",
+		},
+		{
+			name:  "relative path",
+			admin: map[string]interface{}{"path": "__cors", "token": "secret"},
+			err:   `security/cors.admin.path: invalid admin path: it must start with "/"`,
+		},
+		{
+			name:  "port out of range",
+			admin: map[string]interface{}{"port": 70000, "token": "secret"},
+			err:   "security/cors.admin.port: invalid admin port: 70000 is out of range",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{"admin": tc.admin}})
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if cfg.Admin != nil {
+				t.Errorf("the admin handler should not be enabled: %+v", cfg.Admin)
+			}
+		})
+	}
+
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"strict": true,
+		"admin":  map[string]interface{}{"path": "/__cors", "token": "secret", "tokn": "secret"},
+	}})
+	if err == nil || err.Error() != `1 invalid value(s) in security/cors: security/cors.admin.tokn: unknown key, did you mean "token"?` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if cfg.Admin == nil || cfg.Admin.Path != "/__cors" || cfg.Admin.Token != "secret" {
+		t.Errorf("unexpected config: %+v", cfg.Admin)
+	}
+}
+
+func TestLivePolicy_admin(t *testing.T) {
+	lp, err := NewLivePolicy(Config{
+		AllowOrigins:     []string{"https://www.example.com"},
+		AllowMethods:     []string{"GET"},
+		AllowCredentials: true,
+		Admin:            &AdminConfig{Path: "/__cors", Token: "secret"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := lp.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) }))
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "https://example.com/__cors", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+	preflight := func(origin, method string) string {
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if res := do("GET", "", ""); res.Code != http.StatusUnauthorized || res.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("unexpected response without a token: %d %v", res.Code, res.Header())
+	}
+	if res := do("GET", "wrong", ""); res.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected response with a wrong token: %d", res.Code)
+	}
+	if res := do("DELETE", "secret", ""); res.Code != http.StatusMethodNotAllowed || res.Header().Get("Allow") != "GET, PATCH" {
+		t.Errorf("unexpected response to a DELETE: %d %v", res.Code, res.Header())
+	}
+
+	res := do("GET", "secret", "")
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", res.Code)
+		return
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Error(err)
+		return
+	}
+	if body["options_success_status"] != 204.0 || body["admin"].(map[string]interface{})["path"] != "/__cors" {
+		t.Errorf("unexpected config: %s", res.Body.String())
+	}
+	if strings.Contains(res.Body.String(), "secret") {
+		t.Errorf("the token should not be returned: %s", res.Body.String())
+	}
+
+	if preflight("https://partner.example.org", "GET") != "" {
+		t.Error("the partner origin should not be allowed yet")
+	}
+	res = do("PATCH", "secret", `{"add":{"allow_origins":["HTTPS://Partner.example.org:443"],"allow_methods":["put"]}}`)
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d %s", res.Code, res.Body.String())
+		return
+	}
+	if got := strings.Join(lp.Config().AllowOrigins, " "); got != "https://www.example.com https://partner.example.org" {
+		t.Errorf("unexpected origins: %s", got)
+	}
+	if preflight("https://partner.example.org", "PUT") != "https://partner.example.org" {
+		t.Error("the partner origin should be allowed")
+	}
+
+	res = do("PATCH", "secret", `{"remove":{"allow_origins":["https://www.example.com"]}}`)
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d %s", res.Code, res.Body.String())
+	}
+	if preflight("https://www.example.com", "GET") != "" {
+		t.Error("the removed origin should not be allowed")
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		err    string
+	}{
+		{`{"add":{"allow_origins":["https://example.com/path"]}}`, http.StatusUnprocessableEntity, "invalid patch: origin"},
+		{`{"remove":{"allow_origins":["https://partner.example.org"]}}`, http.StatusUnprocessableEntity, "no allowed origins would be left"},
+		{`{"remove":{"allow_methods":["GET","PUT"]}}`, http.StatusUnprocessableEntity, "no allowed methods would be left"},
+		{`{"add":{"allow_origins":["*"]}}`, http.StatusUnprocessableEntity, "insecure CORS configuration: credentials-wildcard-origin"},
+		{`{"add":{"allow_originz":["https://example.com"]}}`, http.StatusBadRequest, "invalid body"},
+	} {
+		res := do("PATCH", "secret", tc.body)
+		if res.Code != tc.status || !strings.Contains(res.Body.String(), tc.err) {
+			t.Errorf("unexpected response to %s: %d %s", tc.body, res.Code, res.Body.String())
+		}
+	}
+	if got := strings.Join(lp.Config().AllowOrigins, " "); got != "https://partner.example.org" {
+		t.Errorf("the rejected patches should not change the policy: %s", got)
+	}
+}
+
+func TestLivePolicy_Update_concurrent(t *testing.T) {
+	lp, err := NewLivePolicy(Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		Admin:        &AdminConfig{Path: "/__cors", Token: "secret"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := lp.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+				req.Header.Set("Origin", "https://www.example.com")
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				if res.Header().Get("Access-Control-Allow-Origin") != "https://www.example.com" {
+					t.Error("the origin should be allowed by every policy")
+					return
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			_, err := lp.Update(func(cfg Config) (Config, error) {
+				cfg.AllowOrigins = append(append([]string{}, cfg.AllowOrigins...), "https://partner"+string(rune('a'+i))+".example.org")
+				return cfg, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(lp.Config().AllowOrigins); n != 9 {
+		t.Errorf("some updates were lost: %v", lp.Config().AllowOrigins)
+	}
+}
+
+func TestLivePolicy_Derive(t *testing.T) {
+	lp, err := NewLivePolicy(Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		Admin:        &AdminConfig{Path: "/__cors", Token: "secret"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	derived, err := lp.Derive(func(cfg Config) Config {
+		cfg.AllowCredentials = true
+		if contains(cfg.AllowOrigins, "https://invalid.example.org") {
+			cfg.OriginValidator = "unknown"
+		}
+		return cfg
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if derived.Config().Admin != nil || !derived.Config().AllowCredentials {
+		t.Errorf("unexpected derived config: %+v", derived.Config())
+	}
+
+	if _, err := lp.Update(func(cfg Config) (Config, error) {
+		cfg.AllowOrigins = append(cfg.AllowOrigins, "https://partner.example.org")
+		return cfg, nil
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+	if got := strings.Join(derived.Config().AllowOrigins, " "); got != "https://www.example.com https://partner.example.org" {
+		t.Errorf("the derived policy should follow the updates: %s", got)
+	}
+
+	if _, err := lp.Update(func(cfg Config) (Config, error) {
+		cfg.AllowOrigins = append(cfg.AllowOrigins, "https://invalid.example.org")
+		return cfg, nil
+	}); err == nil {
+		t.Error("the update should fail if a derived policy can not be built")
+	}
+	for _, p := range []*LivePolicy{lp, derived} {
+		if n := len(p.Config().AllowOrigins); n != 2 {
+			t.Errorf("the failed update should not change any policy: %v", p.Config().AllowOrigins)
+		}
+	}
+}
+
+func TestLivePolicy_Close(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	origins := func() string {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/", port), http.NoBody)
+		req.Header.Add("Authorization", "Bearer secret")
+		for i := 0; i < 100; i++ {
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			defer res.Body.Close()
+			var body map[string]interface{}
+			json.NewDecoder(res.Body).Decode(&body)
+			return fmt.Sprint(body["allow_origins"])
+		}
+		return ""
+	}
+
+	for _, origin := range []string{"https://www.example.com", "https://partner.example.org"} {
+		lp, err := NewLivePolicy(Config{
+			AllowOrigins: []string{origin},
+			Admin:        &AdminConfig{Port: port, Token: "secret"},
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if got := origins(); got != "["+origin+"]" {
+			t.Errorf("unexpected origins served by the admin server: %s", got)
+		}
+		if err := lp.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLivePolicy_admin_origins(t *testing.T) {
+	patch := func(lp *LivePolicy) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "https://example.com/__cors", strings.NewReader(`{"add":{"allow_origins":["https://partner.example.org"]}}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		res := httptest.NewRecorder()
+		lp.Handler(http.NotFoundHandler()).ServeHTTP(res, req)
+		return res
+	}
+	admin := &AdminConfig{Path: "/__cors", Token: "secret"}
+	never := OriginValidatorFunc(func(*http.Request, string) (bool, string) { return false, "never" })
+
+	for name, tc := range map[string]struct {
+		cfg  Config
+		opts []Option
+	}{
+		"validator": {cfg: Config{
+			AllowOriginsRegex: []string{`https://.*\.example\.com`},
+			OriginValidator:   OriginValidatorRegex,
+			Admin:             admin,
+		}},
+		"injected validator": {cfg: Config{Admin: admin}, opts: []Option{WithOriginValidator(never)}},
+	} {
+		lp, err := NewLivePolicy(tc.cfg, nil, tc.opts...)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if res := patch(lp); res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body.String(), "decided by the origin validator") {
+			t.Errorf("%s: unexpected response: %d %s", name, res.Code, res.Body.String())
+		}
+	}
+
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins": []interface{}{"https://www.example.com"},
+		"admin":         map[string]interface{}{"path": "/__cors", "token": "secret"},
+		"tenants": map[string]interface{}{
+			"api.example.com":    map[string]interface{}{"allow_credentials": true},
+			"closed.example.com": map[string]interface{}{"allow_origins": []interface{}{"https://closed.example.org"}},
+		},
+	}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lp, err := NewLivePolicy(cfg, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if res := patch(lp); res.Code != http.StatusOK {
+		t.Errorf("unexpected response: %d %s", res.Code, res.Body.String())
+		return
+	}
+	for host, allowed := range map[string]bool{"api.example.com": true, "closed.example.com": false} {
+		req, _ := http.NewRequest("GET", "https://"+host+"/foo", http.NoBody)
+		req.Header.Set("Origin", "https://partner.example.org")
+		res := httptest.NewRecorder()
+		lp.Handler(http.NotFoundHandler()).ServeHTTP(res, req)
+		if got := res.Header().Get("Access-Control-Allow-Origin") != ""; got != allowed {
+			t.Errorf("%s: the patched origin allowed: %v", host, got)
+		}
+	}
+}
+
+func TestLivePolicy_admin_validatorConfig(t *testing.T) {
+	lp, err := NewLivePolicy(Config{
+		AllowOriginsRegex:     []string{`https://.*\.example\.com`},
+		OriginValidator:       OriginValidatorRegex,
+		OriginValidatorConfig: map[string]interface{}{"dsn": "postgres://cors:s3cr3t@db/origins"},
+		Tenants: map[string]Config{
+			"api.example.com": {OriginValidatorConfig: map[string]interface{}{"dsn": "postgres://cors:s3cr3t@db/api"}},
+		},
+		Admin: &AdminConfig{Path: "/__cors", Token: "secret"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/__cors", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	res := httptest.NewRecorder()
+	lp.Handler(http.NotFoundHandler()).ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", res.Code)
+	}
+	if body := res.Body.String(); strings.Contains(body, "origin_validator_config") || strings.Contains(body, "s3cr3t") {
+		t.Errorf("the options of the origin validator should not be returned: %s", body)
+	}
+}
@@ -0,0 +1,255 @@
+package cors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// The supported outputs of the audit log
+const (
+	AuditStdout = "stdout"
+	AuditStderr = "stderr"
+	AuditFile   = "file"
+)
+
+// DefaultAuditDedupWindow is the default time during which the repeated rejections are not written
+// to the audit log again
+const DefaultAuditDedupWindow = time.Minute
+
+// maxAuditKeys bounds the number of distinct rejections tracked by the deduplication
+const maxAuditKeys = 10000
+
+// AuditConfig holds the configuration of the audit log
+type AuditConfig struct {
+	// Output is the sink of the records: AuditStdout (the default), AuditStderr or AuditFile
+	Output string
+	// Path is the file the records are appended to when the output is AuditFile
+	Path string
+	// DedupWindow is the time during which the rejections with the same origin, method and reason
+	// are written only once, whatever their path is
+	DedupWindow time.Duration
+}
+
+// auditKeys lists all the options accepted in the audit object
+var auditKeys = []string{
+	"output",
+	"path",
+	"dedup_window",
+}
+
+func parseAuditConfig(p *parser) *AuditConfig {
+	cfg := &AuditConfig{
+		Output:      p.string("output"),
+		Path:        p.string("path"),
+		DedupWindow: p.duration("dedup_window"),
+	}
+	if cfg.Output == "" && cfg.Path != "" {
+		cfg.Output = AuditFile
+	}
+	switch cfg.Output {
+	case "", AuditStdout, AuditStderr:
+	case AuditFile:
+		if cfg.Path == "" {
+			p.fail("path", p.path("path"), "", "audit file", errors.New("required by the file output"))
+		}
+	default:
+		p.fail("output", p.path("output"), cfg.Output, "audit output", fmt.Errorf("unknown output %q", cfg.Output))
+		cfg.Output = ""
+	}
+	return cfg
+}
+
+// AuditRecord is the entry written, as a JSON line, to the audit log for every rejected cross-origin
+// request
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Origin    string    `json:"origin"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Preflight bool      `json:"preflight"`
+	// RequestedMethod and RequestedHeaders are the ones declared by the preflights
+	RequestedMethod  string `json:"requested_method,omitempty"`
+	RequestedHeaders string `json:"requested_headers,omitempty"`
+	ClientIP         string `json:"client_ip"`
+	ForwardedFor     string `json:"forwarded_for,omitempty"`
+	Reason           Reason `json:"reason"`
+	// Suppressed counts the identical rejections not written since the previous record
+	Suppressed int `json:"suppressed,omitempty"`
+	// Dropped counts the rejections not written because too many distinct ones were being tracked
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// WithAuditWriter writes the audit log to w instead of the output in the configuration, enabling
+// it if the configuration does not. The writes are serialized, so the same writer can be shared by
+// several policies.
+func WithAuditWriter(w io.Writer) Option {
+	sw := &syncWriter{w: w}
+	return func(p *Policy) {
+		p.audit = newAuditor(sw, p.cfg.Audit, p.logger)
+	}
+}
+
+// newAuditSink returns the writer for the output in the configuration. The files are opened once
+// and shared by all the policies writing to them.
+func newAuditSink(cfg *AuditConfig) (io.Writer, error) {
+	switch cfg.Output {
+	case AuditFile:
+		return openAuditFile(cfg.Path)
+	case AuditStderr:
+		return stderr, nil
+	default:
+		return stdout, nil
+	}
+}
+
+var (
+	stdout = &syncWriter{w: os.Stdout}
+	stderr = &syncWriter{w: os.Stderr}
+
+	auditFilesMu sync.Mutex
+	auditFiles   = map[string]*syncWriter{}
+)
+
+func openAuditFile(path string) (*syncWriter, error) {
+	auditFilesMu.Lock()
+	defer auditFilesMu.Unlock()
+
+	if w, ok := auditFiles[path]; ok {
+		return w, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening the audit log: %w", err)
+	}
+	w := &syncWriter{w: f}
+	auditFiles[path] = w
+	return w, nil
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
+
+type auditor struct {
+	w      io.Writer
+	window time.Duration
+	l      logging.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	seen    map[string]*auditEntry
+	dropped int
+}
+
+type auditEntry struct {
+	last       time.Time
+	suppressed int
+}
+
+func newAuditor(w io.Writer, cfg *AuditConfig, l logging.Logger) *auditor {
+	window := DefaultAuditDedupWindow
+	if cfg != nil && cfg.DedupWindow > 0 {
+		window = cfg.DedupWindow
+	}
+	if l == nil {
+		l = logging.NoOp
+	}
+	return &auditor{
+		w:      w,
+		window: window,
+		l:      l,
+		now:    time.Now,
+		seen:   map[string]*auditEntry{},
+	}
+}
+
+// record writes the rejected request to the audit log unless the same rejection was already
+// written during the deduplication window
+func (a *auditor) record(r *http.Request, d decision) {
+	rec := AuditRecord{
+		Origin:       d.origin,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		Preflight:    d.preflight,
+		ClientIP:     clientIP(r),
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Reason:       d.reason,
+	}
+	if d.preflight {
+		rec.RequestedMethod = d.method
+		rec.RequestedHeaders = d.headers
+	}
+
+	// the path is left out of the key, so a page requesting random paths gets a single record
+	key := strings.Join([]string{d.origin, d.method, string(d.reason)}, "|")
+
+	a.mu.Lock()
+	now := a.now()
+	rec.Time = now
+	if e, ok := a.seen[key]; ok {
+		if now.Sub(e.last) < a.window {
+			e.suppressed++
+			a.mu.Unlock()
+			return
+		}
+		rec.Suppressed = e.suppressed
+		e.last, e.suppressed = now, 0
+	} else {
+		if len(a.seen) >= maxAuditKeys {
+			a.expire(now)
+		}
+		if len(a.seen) >= maxAuditKeys {
+			a.dropped++
+			a.mu.Unlock()
+			return
+		}
+		a.seen[key] = &auditEntry{last: now}
+	}
+	rec.Dropped, a.dropped = a.dropped, 0
+	a.mu.Unlock()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		a.l.Warning(logPrefix, "Unable to encode the audit record:", err.Error())
+		return
+	}
+	if _, err := a.w.Write(append(b, '\n')); err != nil {
+		a.l.Warning(logPrefix, "Unable to write the audit record:", err.Error())
+	}
+}
+
+// expire forgets the rejections whose deduplication window is over. The suppressed repetitions of
+// the forgotten rejections are counted as dropped.
+func (a *auditor) expire(now time.Time) {
+	for k, e := range a.seen {
+		if now.Sub(e.last) >= a.window {
+			a.dropped += e.suppressed
+			delete(a.seen, k)
+		}
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
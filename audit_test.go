@@ -0,0 +1,217 @@
+package cors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig_audit(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "https://example.com" ],
+			"audit": {
+				"path": "/var/log/cors.log",
+				"dedup_window": "5m"
+			}
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	cfg, err := ParseConfig(sampleCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cfg.Audit == nil {
+		t.Error("the audit log should be enabled")
+		return
+	}
+	if *cfg.Audit != (AuditConfig{Output: AuditFile, Path: "/var/log/cors.log", DedupWindow: 5 * time.Minute}) {
+		t.Errorf("unexpected audit config: %+v", *cfg.Audit)
+	}
+}
+
+func TestParseConfig_auditInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		audit interface{}
+		msg   string
+	}{
+		{
+			audit: true,
+			msg:   `security/cors.audit: got boolean, expected object`,
+		},
+		{
+			audit: map[string]interface{}{"output": "syslog"},
+			msg:   `security/cors.audit.output: invalid audit output: unknown output "syslog"`,
+		},
+		{
+			audit: map[string]interface{}{"output": "file"},
+			msg:   `security/cors.audit.path: invalid audit file: required by the file output`,
+		},
+		{
+			audit: map[string]interface{}{"output": "stderr", "dedup_windows": "1m"},
+			msg:   `security/cors.audit.dedup_windows: unknown key, did you mean "dedup_window"?`,
+		},
+	} {
+		_, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{"audit": tc.audit, "strict": true}})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if errs[0].Error() != tc.msg {
+			t.Errorf("unexpected error: %s", errs[0].Error())
+		}
+	}
+}
+
+func TestWithAuditWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p, err := NewPolicy(Config{
+		AllowOrigins: []string{"https://example.com"},
+		AllowMethods: []string{"GET", "PUT"},
+		AllowHeaders: []string{"X-Test"},
+	}, nil, WithAuditWriter(buf))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, headers := range []map[string]string{
+		{},
+		{"Origin": "https://example.com"},
+		{"Origin": "https://evil.example.com", "X-Forwarded-For": "10.0.0.1"},
+		{"Origin": "https://example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-other"},
+	} {
+		method := "GET"
+		if headers["Access-Control-Request-Method"] != "" {
+			method = "OPTIONS"
+		}
+		req, _ := http.NewRequest(method, "https://example.com/foo", http.NoBody)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Errorf("unexpected audit log: %s", buf.String())
+		return
+	}
+	expected := []AuditRecord{
+		{
+			Origin:       "https://evil.example.com",
+			Method:       "GET",
+			Path:         "/foo",
+			ClientIP:     "192.0.2.1",
+			ForwardedFor: "10.0.0.1",
+			Reason:       ReasonOrigin,
+		},
+		{
+			Origin:           "https://example.com",
+			Method:           "OPTIONS",
+			Path:             "/foo",
+			Preflight:        true,
+			RequestedMethod:  "PUT",
+			RequestedHeaders: "x-other",
+			ClientIP:         "192.0.2.1",
+			Reason:           ReasonHeader,
+		},
+	}
+	for i, line := range lines {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Error(err)
+			continue
+		}
+		if rec.Time.IsZero() {
+			t.Errorf("record #%d without time: %s", i, line)
+		}
+		rec.Time = time.Time{}
+		if rec != expected[i] {
+			t.Errorf("unexpected record #%d: %s", i, line)
+		}
+	}
+}
+
+func TestAuditor_dedup(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := newAuditor(buf, &AuditConfig{DedupWindow: time.Minute}, nil)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+	d := decision{origin: "https://evil.example.com", method: "GET", reason: ReasonOrigin}
+
+	// the requests to random paths are deduplicated too
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("https://example.com/foo/%d", i), http.NoBody)
+		a.record(req, d)
+		now = now.Add(10 * time.Second)
+	}
+	other := d
+	other.origin = "https://other.example.com"
+	a.record(req, other)
+
+	now = now.Add(time.Minute)
+	a.record(req, d)
+
+	var suppressed []int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Error(err)
+			return
+		}
+		suppressed = append(suppressed, rec.Suppressed)
+	}
+	if len(suppressed) != 3 || suppressed[0] != 0 || suppressed[1] != 0 || suppressed[2] != 4 {
+		t.Errorf("unexpected audit log: %s", buf.String())
+	}
+}
+
+func TestNewPolicy_auditFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cfg := Config{
+		AllowOrigins: []string{"https://example.com"},
+		Audit:        &AuditConfig{Output: AuditFile, Path: path},
+	}
+	for i := 0; i < 2; i++ {
+		p, err := NewPolicy(cfg, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", "https://evil.example.com")
+		p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n := strings.Count(string(b), "\n"); n != 2 {
+		t.Errorf("unexpected number of records: %d", n)
+	}
+
+	cfg.Audit.Path = filepath.Join(t.TempDir(), "missing", "audit.log")
+	if _, err := NewPolicy(cfg, nil); err == nil {
+		t.Error("an error was expected")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	krakendcors "github.com/krakend/krakend-cors/v3"
+	"github.com/luraproject/lura/v3/config"
+)
+
+// serviceConfig holds the parts of a KrakenD config file used by the CORS module. It is decoded
+// directly, without the rest of the validations of the gateway, so partial configs can be checked.
+type serviceConfig struct {
+	ExtraConfig map[string]interface{} `json:"extra_config"`
+	Endpoints   []struct {
+		Endpoint     string                 `json:"endpoint"`
+		Method       string                 `json:"method"`
+		InputHeaders []string               `json:"input_headers"`
+		ExtraConfig  map[string]interface{} `json:"extra_config"`
+	} `json:"endpoints"`
+}
+
+// readConfig parses the config file, returning its contents too
+func readConfig(path string) (serviceConfig, []byte, error) {
+	var cfg serviceConfig
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, nil, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, b, nil
+}
+
+// serviceConfig converts the parsed file to the config of the gateway
+func (c serviceConfig) serviceConfig() config.ServiceConfig {
+	cfg := config.ServiceConfig{ExtraConfig: c.ExtraConfig}
+	for _, e := range c.Endpoints {
+		cfg.Endpoints = append(cfg.Endpoints, &config.EndpointConfig{
+			Endpoint:      e.Endpoint,
+			Method:        e.Method,
+			HeadersToPass: e.InputHeaders,
+			ExtraConfig:   e.ExtraConfig,
+		})
+	}
+	return cfg
+}
+
+// withoutSideEffects returns a copy of the config without the admin handler and the audit log of the
+// service, the endpoints and the tenants, so building the policies neither opens ports nor writes
+// records
+func (c serviceConfig) withoutSideEffects() serviceConfig {
+	c.ExtraConfig = stripSideEffects(c.ExtraConfig)
+	c.Endpoints = append(c.Endpoints[:0:0], c.Endpoints...)
+	for i := range c.Endpoints {
+		c.Endpoints[i].ExtraConfig = stripSideEffects(c.Endpoints[i].ExtraConfig)
+	}
+	return c
+}
+
+func stripSideEffects(extra map[string]interface{}) map[string]interface{} {
+	ns, ok := extra[krakendcors.Namespace].(map[string]interface{})
+	if !ok {
+		return extra
+	}
+	out := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		out[k] = v
+	}
+	ns = withoutKeys(ns, "admin", "audit")
+	if tenants, ok := ns["tenants"].(map[string]interface{}); ok {
+		stripped := make(map[string]interface{}, len(tenants))
+		for host, t := range tenants {
+			if tenant, ok := t.(map[string]interface{}); ok {
+				t = withoutKeys(tenant, "audit")
+			}
+			stripped[host] = t
+		}
+		ns["tenants"] = stripped
+	}
+	out[krakendcors.Namespace] = ns
+	return out
+}
+
+func withoutKeys(m map[string]interface{}, keys ...string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, k := range keys {
+		delete(out, k)
+	}
+	return out
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	krakendcors "github.com/krakend/krakend-cors/v3"
+	"github.com/luraproject/lura/v3/config"
+)
+
+// issue is a problem found in a CORS namespace of the config file
+type issue struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	// Path locates the offending value in the config file
+	Path string `json:"path"`
+	// Endpoint is the method and path of the endpoint declaring the namespace, if any
+	Endpoint string `json:"endpoint,omitempty"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// The rules of the parsing problems. The rest are the ones of the security checks.
+const (
+	ruleInvalidValue = "invalid-value"
+	ruleUnknownKey   = "unknown-key"
+)
+
+// lint reports the problems of the CORS namespaces of the service and the endpoints: the invalid
+// values, the unknown keys and the findings of the security checks. It exits with exitBlocked if
+// any of them is an error.
+func lint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("c", "krakend.json", "path of the KrakenD config file")
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	write, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return exitError
+	}
+
+	cfg, b, err := readConfig(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	issues := lintNamespace(cfg.ExtraConfig, nil, "extra_config")
+	for i, e := range cfg.Endpoints {
+		if _, ok := e.ExtraConfig[krakendcors.Namespace]; !ok {
+			continue
+		}
+		endpoint := strings.ToUpper(e.Method)
+		if endpoint == "" {
+			endpoint = "GET"
+		}
+		for _, is := range lintNamespace(e.ExtraConfig, cfg.ExtraConfig, fmt.Sprintf("endpoints[%d].extra_config", i)) {
+			is.Endpoint = endpoint + " " + e.Endpoint
+			issues = append(issues, is)
+		}
+	}
+	lines := lineIndex(b)
+	for i := range issues {
+		issues[i].Line = lines.find(issues[i].Path)
+	}
+
+	if err := write(stdout, *path, issues); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	for _, is := range issues {
+		if is.Severity == krakendcors.SeverityError.String() {
+			return exitBlocked
+		}
+	}
+	return exitOK
+}
+
+// lintNamespace parses the namespace in strict mode, so the unknown keys are always reported, and
+// runs the security checks. The namespaces of the endpoints are merged over the service one, as the
+// middleware does, but only the problems not inherited from it are reported.
+func lintNamespace(extra, service config.ExtraConfig, prefix string) []issue {
+	ns, ok := extra[krakendcors.Namespace]
+	if !ok {
+		return nil
+	}
+	own, _ := ns.(map[string]interface{})
+	if service != nil {
+		extra = krakendcors.MergeExtraConfig(service, extra)
+	}
+	if m, ok := extra[krakendcors.Namespace].(map[string]interface{}); ok {
+		strict := make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			strict[k] = v
+		}
+		strict["strict"] = true
+		extra = config.ExtraConfig{krakendcors.Namespace: strict}
+	}
+
+	var issues []issue
+	cfg, err := krakendcors.ParseConfig(extra)
+	var errs krakendcors.ValidationErrors
+	errors.As(err, &errs)
+	for _, fe := range errs {
+		if key, _, _ := strings.Cut(fe.Key, "."); service != nil && own != nil && fe.Key != "" {
+			if _, declared := own[key]; !declared {
+				continue
+			}
+		}
+		is := issue{
+			Severity: krakendcors.SeverityError.String(),
+			Rule:     ruleInvalidValue,
+			Message:  strings.TrimPrefix(fe.Error(), fe.Path+": "),
+			Path:     prefix + "." + fe.Path,
+		}
+		if errors.Is(fe, krakendcors.ErrUnknownKey) {
+			is.Severity = krakendcors.SeverityWarning.String()
+			is.Rule = ruleUnknownKey
+		}
+		issues = append(issues, is)
+	}
+
+	inherited := map[krakendcors.Finding]bool{}
+	if service != nil {
+		if serviceCfg, err := krakendcors.ParseConfig(service); err != krakendcors.ErrNoConfig {
+			for _, f := range serviceCfg.Lint() {
+				inherited[f] = true
+			}
+		}
+	}
+	for _, f := range cfg.Lint() {
+		if inherited[f] {
+			continue
+		}
+		is := issue{
+			Severity: f.Severity.String(),
+			Rule:     f.Rule,
+			Message:  f.Message,
+			Path:     prefix + "." + krakendcors.Namespace,
+		}
+		if f.Key != "" {
+			is.Path += "." + f.Key
+		}
+		issues = append(issues, is)
+	}
+	return issues
+}
+
+// lines maps the paths of the values of a JSON document (Ex: "endpoints[1].extra_config") to the
+// lines where they start
+type lines map[string]int
+
+func lineIndex(b []byte) lines {
+	l := lines{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	line := func() int {
+		off := int(dec.InputOffset())
+		for off < len(b) && strings.IndexByte(" \t\r\n,:", b[off]) >= 0 {
+			off++
+		}
+		return bytes.Count(b[:off], []byte("\n")) + 1
+	}
+	var value func(path string) error
+	value = func(path string) error {
+		l[path] = line()
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				p, _ := key.(string)
+				if path != "" {
+					p = path + "." + p
+				}
+				if err := value(p); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := value(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	value("")
+	return l
+}
+
+// find returns the line of the path or, if it is not in the document, the one of its closest parent
+func (l lines) find(path string) int {
+	for path != "" {
+		if n, ok := l[path]; ok {
+			return n
+		}
+		path = path[:max(strings.LastIndexAny(path, ".["), 0)]
+	}
+	return 0
+}
+
+var formats = map[string]func(w io.Writer, file string, issues []issue) error{
+	"text":  writeText,
+	"json":  writeJSON,
+	"sarif": writeSARIF,
+}
+
+func writeText(w io.Writer, file string, issues []issue) error {
+	errs := 0
+	for _, is := range issues {
+		location := is.Path
+		if is.Endpoint != "" {
+			location += " (" + is.Endpoint + ")"
+		}
+		fmt.Fprintf(w, "%s:%d: %s [%s] %s: %s\n", file, is.Line, is.Severity, is.Rule, location, is.Message)
+		if is.Severity == krakendcors.SeverityError.String() {
+			errs++
+		}
+	}
+	if len(issues) == 0 {
+		_, err := fmt.Fprintln(w, "No problems found")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errs, len(issues)-errs)
+	return err
+}
+
+func writeJSON(w io.Writer, file string, issues []issue) error {
+	if issues == nil {
+		issues = []issue{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{"file": file, "issues": issues})
+}
+
+// writeSARIF writes the issues as a SARIF 2.1.0 log, the format read by most code review tools
+func writeSARIF(w io.Writer, file string, issues []issue) error {
+	type region struct {
+		StartLine int `json:"startLine"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *region `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+		LogicalLocations []map[string]string `json:"logicalLocations"`
+	}
+	type result struct {
+		RuleID  string            `json:"ruleId"`
+		Level   string            `json:"level"`
+		Message map[string]string `json:"message"`
+		Locs    []location        `json:"locations"`
+	}
+
+	rules := []map[string]string{}
+	seen := map[string]bool{}
+	results := make([]result, 0, len(issues))
+	for _, is := range issues {
+		if !seen[is.Rule] {
+			seen[is.Rule] = true
+			rules = append(rules, map[string]string{"id": is.Rule})
+		}
+		var loc location
+		loc.PhysicalLocation.ArtifactLocation.URI = file
+		if is.Line > 0 {
+			loc.PhysicalLocation.Region = &region{StartLine: is.Line}
+		}
+		loc.LogicalLocations = []map[string]string{{"fullyQualifiedName": is.Path}}
+		msg := is.Message
+		if is.Endpoint != "" {
+			msg = is.Endpoint + ": " + msg
+		}
+		results = append(results, result{
+			RuleID:  is.Rule,
+			Level:   is.Severity,
+			Message: map[string]string{"text": msg},
+			Locs:    []location{loc},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":           "krakend-cors",
+				"informationUri": "https://github.com/krakend/krakend-cors",
+				"rules":          rules,
+			}},
+			"results": results,
+		}},
+	})
+}
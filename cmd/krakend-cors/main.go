@@ -0,0 +1,54 @@
+// Command krakend-cors checks the security/cors configuration of a KrakenD config file offline.
+//
+// Usage:
+//
+//	krakend-cors simulate -c krakend.json -origin https://app.example.com -method PUT -path /users -preflight
+//	krakend-cors lint -c krakend.json -format sarif
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// The exit codes of the commands
+const (
+	exitOK      = 0
+	exitBlocked = 1
+	exitError   = 2
+)
+
+var commands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"simulate": simulate,
+	"lint":     lint,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return exitError
+	}
+	return cmd(args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: krakend-cors <command> [flags]
+
+Commands:
+  simulate  evaluate a request against the CORS policies of a KrakenD config file
+  lint      report the problems of the CORS configuration of a KrakenD config file
+
+Run "krakend-cors <command> -h" for the flags of a command.
+`)
+}
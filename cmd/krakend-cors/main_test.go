@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_simulate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		code   int
+		output []string
+	}{
+		{
+			name: "allowed request",
+			args: []string{"-origin", "https://www.example.com", "-path", "/users/1"},
+			code: exitOK,
+			output: []string{
+				"Request GET /users/1 from https://www.example.com: allowed",
+				"origin       https://www.example.com  ok  https://www.example.com",
+				"passed to the backends",
+				"Access-Control-Allow-Origin: https://www.example.com",
+			},
+		},
+		{
+			name: "allowed preflight",
+			args: []string{
+				"-origin", "https://www.example.com", "-preflight", "-method", "post", "-path", "/users/1",
+				"-request-headers", "Authorization",
+			},
+			code: exitOK,
+			output: []string{
+				"Preflight POST /users/1 from https://www.example.com: allowed",
+				"header       authorization            ok  authorization",
+				"204 No Content",
+				"Access-Control-Max-Age: 43200",
+			},
+		},
+		{
+			name: "endpoint policy",
+			args: []string{
+				"-origin", "https://www.example.com", "-preflight", "-method", "PUT", "-path", "/partners",
+				"-request-headers", "X-Debug,Authorization",
+			},
+			code: exitBlocked,
+			output: []string{
+				"Preflight PUT /partners from https://www.example.com: blocked (origin)",
+				"origin       https://www.example.com  no  not in the allowed origins",
+				"header       x-debug                  no  not in allow_headers",
+			},
+		},
+		{
+			name: "invalid config",
+			args: []string{"-c", "testdata/problems.json", "-origin", "https://www.example.com", "-path", "/users/1"},
+			code: exitError,
+			output: []string{
+				"testdata/problems.json: invalid CORS policy of the service: 2 invalid value(s) in security/cors:",
+				"security/cors.allow_origins[1]: invalid origin: a path (or a trailing slash) is not allowed",
+			},
+		},
+		{
+			name: "insecure endpoint config",
+			args: []string{"-c", "testdata/insecure.json", "-origin", "https://www.example.com", "-path", "/partners"},
+			code: exitError,
+			output: []string{
+				"testdata/insecure.json: invalid CORS policy of the endpoint GET /partners: insecure CORS configuration",
+			},
+		},
+		{
+			name:   "missing config",
+			args:   []string{"-c", "testdata/missing.json"},
+			code:   exitError,
+			output: []string{"no such file or directory"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			args := append([]string{"simulate", "-c", "testdata/krakend.json"}, tc.args...)
+			if code := run(args, stdout, stderr); code != tc.code {
+				t.Errorf("unexpected exit code %d: %s%s", code, stdout.String(), stderr.String())
+			}
+			// the columns of the checks are aligned with a variable number of spaces
+			out := strings.Join(strings.Fields(stdout.String()+stderr.String()), " ")
+			for _, msg := range tc.output {
+				if !strings.Contains(out, strings.Join(strings.Fields(msg), " ")) {
+					t.Errorf("%q not found in the output:\n%s", msg, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_unknownCommand(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	if code := run([]string{"simulat"}, &bytes.Buffer{}, stderr); code != exitError {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "simulat"`) {
+		t.Errorf("unexpected output: %s", stderr.String())
+	}
+}
+
+func TestRun_lint(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"lint", "-c", "testdata/problems.json"}, stdout, stderr); code != exitBlocked {
+		t.Errorf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	expected := "testdata/problems.json:5: error [invalid-value] extra_config.security/cors.allow_origins[1]: " +
+		"invalid origin: a path (or a trailing slash) is not allowed\n" +
+		"testdata/problems.json:8: error [invalid-value] extra_config.security/cors.max_age: " +
+		"got number, expected duration string\n" +
+		"testdata/problems.json:10: warning [unknown-key] extra_config.security/cors.alow_credentials: " +
+		"unknown key, did you mean \"allow_credentials\"?\n" +
+		"testdata/problems.json:5: warning [duplicate-entries] extra_config.security/cors.allow_origins: " +
+		"allow_origins lists https://www.example.com more than once\n" +
+		"testdata/problems.json:5: warning [unreachable-origins] extra_config.security/cors.allow_origins: " +
+		"https://www.example.com is already allowed by https://*.example.com\n" +
+		"testdata/problems.json:25: error [credentials-wildcard-origin] " +
+		"endpoints[1].extra_config.security/cors.allow_credentials (PUT /partners): " +
+		"allow_credentials with a wildcard origin lets any site perform authenticated requests\n" +
+		"testdata/problems.json:24: warning [unreachable-origins] " +
+		"endpoints[1].extra_config.security/cors.allow_origins (PUT /partners): " +
+		"the wildcard origin already allows https://*.example.com\n" +
+		"3 error(s), 4 warning(s)\n"
+	if stdout.String() != expected {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"lint", "-c", "testdata/krakend.json", "-format", "json"}, stdout, stderr); code != exitOK {
+		t.Errorf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "{\n  \"file\": \"testdata/krakend.json\",\n  \"issues\": []\n}\n" {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestRun_lint_sarif(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	run([]string{"lint", "-c", "testdata/problems.json", "-format", "sarif"}, stdout, &bytes.Buffer{})
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &log); err != nil {
+		t.Error(err)
+		return
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 7 || len(log.Runs[0].Tool.Driver.Rules) != 5 {
+		t.Errorf("unexpected log: %s", stdout.String())
+		return
+	}
+	r := log.Runs[0].Results[5]
+	if r.RuleID != "credentials-wildcard-origin" || r.Level != "error" || r.Locations[0].PhysicalLocation.Region.StartLine != 25 {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
+
+func TestRun_simulate_sideEffects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	cors := map[string]interface{}{
+		"allow_origins": []string{"https://www.example.com"},
+		"admin":         map[string]interface{}{"port": port, "token": "secret"},
+		"audit":         map[string]interface{}{"output": "file", "path": auditPath},
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"extra_config": map[string]interface{}{"security/cors": cors},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"endpoint":     "/users",
+				"extra_config": map[string]interface{}{"security/cors": map[string]interface{}{"allow_credentials": true}},
+			},
+		},
+	})
+	cfgPath := filepath.Join(dir, "krakend.json")
+	if err := os.WriteFile(cfgPath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/", "/users", "/"} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := []string{"simulate", "-c", cfgPath, "-origin", "https://evil.example.org", "-path", path}
+		if code := run(args, stdout, stderr); code != exitBlocked {
+			t.Errorf("%s: unexpected exit code %d: %s%s", path, code, stdout.String(), stderr.String())
+		}
+	}
+	if _, err := os.Stat(auditPath); !os.IsNotExist(err) {
+		t.Errorf("the simulation should not write audit records: %v", err)
+	}
+	ln, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Errorf("the simulation should not start the admin server: %v", err)
+		return
+	}
+	ln.Close()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	krakendcors "github.com/krakend/krakend-cors/v3"
+	"github.com/krakend/krakend-cors/v3/mux"
+	"github.com/luraproject/lura/v3/config"
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// headerFlags collects the repeated -H flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return errors.New(`headers must be written as "Name: value"`)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// simulate evaluates a request against the CORS policies of a config file as the service middleware
+// of the gateway would, printing the decision and the response. It exits with exitBlocked if the
+// browser would block the request.
+func simulate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("c", "krakend.json", "path of the KrakenD config file")
+	origin := fs.String("origin", "", "origin of the request (empty for a same-origin request)")
+	method := fs.String("method", http.MethodGet, "method of the request (the requested one for a preflight)")
+	urlPath := fs.String("path", "/", "path of the request")
+	host := fs.String("host", "localhost", "host of the request, used to select the tenant")
+	preflight := fs.Bool("preflight", false, "simulate the preflight of the request")
+	requestHeaders := fs.String("request-headers", "", "comma-separated headers requested by the preflight")
+	privateNetwork := fs.Bool("private-network", false, "request access to the private network in the preflight")
+	headers := headerFlags{}
+	fs.Var(headers, "H", `extra header of the request, as "Name: value" (repeatable)`)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	cfg, _, err := readConfig(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if !cfg.hasCORS() {
+		fmt.Fprintf(stderr, "%s: no %s configuration found\n", *path, krakendcors.Namespace)
+		return exitError
+	}
+	// the admin handler and the audit log play no part in the decisions
+	cfg = cfg.withoutSideEffects()
+	if err := cfg.checkPolicies(); err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", *path, err)
+		return exitError
+	}
+	l, _ := logging.NewLogger("WARNING", stderr, "")
+	mw := mux.NewServiceWithLogger(cfg.serviceConfig(), l)
+
+	r, err := http.NewRequest(http.MethodGet, "http://"+*host+*urlPath, http.NoBody)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	r.Header = http.Header(headers)
+	if *origin != "" {
+		r.Header.Set("Origin", *origin)
+	}
+	if *preflight {
+		r.Method = http.MethodOptions
+		r.Header.Set("Access-Control-Request-Method", strings.ToUpper(*method))
+		if names := browserHeaders(*requestHeaders); names != "" {
+			r.Header.Set("Access-Control-Request-Headers", names)
+		}
+		if *privateNetwork {
+			r.Header.Set("Access-Control-Request-Private-Network", "true")
+		}
+	} else {
+		r.Method = strings.ToUpper(*method)
+	}
+
+	d := mux.Explain(mw, r)
+	printDecision(stdout, r, d)
+	if !d.Allowed {
+		return exitBlocked
+	}
+	return exitOK
+}
+
+// hasCORS reports whether the service or any endpoint declares a CORS configuration
+func (c serviceConfig) hasCORS() bool {
+	if krakendcors.ConfigGetter(c.ExtraConfig) != nil {
+		return true
+	}
+	for _, e := range c.Endpoints {
+		if krakendcors.ConfigGetter(e.ExtraConfig) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPolicies returns the first error building the CORS policies of the service and the endpoints,
+// since the middleware leaves the requests covered by an invalid policy without CORS headers, as if
+// they were blocked
+func (c serviceConfig) checkPolicies() error {
+	check := func(name string, e config.ExtraConfig) error {
+		cfg, err := krakendcors.Load(e, nil)
+		if err == krakendcors.ErrNoConfig {
+			return nil
+		}
+		if err == nil {
+			_, err = krakendcors.NewPolicy(cfg, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid CORS policy of the %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := check("service", c.ExtraConfig); err != nil {
+		return err
+	}
+	for _, e := range c.serviceConfig().Endpoints {
+		if _, ok := e.ExtraConfig[krakendcors.Namespace]; !ok {
+			continue
+		}
+		method := strings.ToUpper(e.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		name := fmt.Sprintf("endpoint %s %s", method, e.Endpoint)
+		if err := check(name, krakendcors.MergeExtraConfig(c.ExtraConfig, e.ExtraConfig)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// browserHeaders formats the requested headers as the browsers do: lowercase, sorted and without
+// duplicates
+func browserHeaders(list string) string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func contains(list []string, v string) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func printDecision(w io.Writer, r *http.Request, d krakendcors.Decision) {
+	kind := "Request"
+	if d.Preflight {
+		kind = "Preflight"
+	}
+	verdict := "allowed"
+	if !d.Allowed {
+		verdict = "blocked (" + string(d.Reason) + ")"
+	}
+	if d.Origin == "" {
+		fmt.Fprintf(w, "%s %s %s without origin: %s\n", kind, d.Method, r.URL.Path, verdict)
+	} else {
+		fmt.Fprintf(w, "%s %s %s from %s: %s\n", kind, d.Method, r.URL.Path, d.Origin, verdict)
+	}
+
+	if len(d.Steps) > 0 {
+		fmt.Fprintln(w, "\nChecks:")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, s := range d.Steps {
+			result := "ok"
+			switch {
+			case s.Check == krakendcors.StepCredentials && s.Allowed:
+				result = "on"
+			case s.Check == krakendcors.StepCredentials:
+				result = "off"
+			case !s.Allowed:
+				result = "no"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", s.Check, s.Value, result, s.Rule)
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintln(w, "\nResponse:")
+	if d.Status == 0 {
+		fmt.Fprintln(w, "  passed to the backends")
+	} else {
+		fmt.Fprintf(w, "  %d %s\n", d.Status, http.StatusText(d.Status))
+	}
+	names := make([]string, 0, len(d.Headers))
+	for name := range d.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range d.Headers[name] {
+			fmt.Fprintf(w, "  %s: %s\n", name, v)
+		}
+	}
+}
@@ -1,14 +1,21 @@
 package cors
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/luraproject/lura/v3/config"
+	"github.com/luraproject/lura/v3/logging"
 )
 
 // Namespace is the key to look for extra configuration details
 const Namespace = "security/cors"
 
+// ErrNoConfig is returned by ParseConfig when the extra config does not contain the CORS namespace
+var ErrNoConfig = errors.New("no config for the CORS module")
+
 // Config holds the configuration of CORS
 type Config struct {
 	AllowOrigins         []string
@@ -21,72 +28,313 @@ type Config struct {
 	OptionsSuccessStatus int
 	MaxAge               time.Duration
 	Debug                bool
+	// AllowOriginsRegex holds regular expressions matching the whole allowed origins
+	AllowOriginsRegex []string
+	// AllowOriginsPatterns holds allowed origins with wildcards for the subdomains ("https://*.example.com")
+	// or the port ("http://localhost:*")
+	AllowOriginsPatterns []string
+	// AllowOriginsFile is the path of a file listing more allowed origins, reloaded when it changes
+	AllowOriginsFile string
+	// AllowOriginsFileInterval is the minimum time between two checks for changes in the origins file
+	AllowOriginsFileInterval time.Duration
+	// AutoMethods makes the service middleware allow, for every path, the methods of the
+	// endpoints declared with that path
+	AutoMethods bool
+	// AutoHeaders makes the service middleware allow, for every endpoint, the headers it forwards
+	// to the backends (its input_headers) plus the AlwaysAllowHeaders
+	AutoHeaders bool
+	// AlwaysAllowHeaders lists the headers allowed for every endpoint when AutoHeaders is enabled
+	AlwaysAllowHeaders []string
+	// Compat selects the legacy defaults of a flavour. The only supported value is CompatGin
+	Compat string
+	// Strict makes the parsing fail on unknown keys and the middleware constructors refuse
+	// to build a middleware from an invalid configuration
+	Strict bool
+	// AllowInsecure lets the middleware be built even if the security checks report errors
+	AllowInsecure bool
+	// Audit enables the audit log of the rejected cross-origin requests
+	Audit *AuditConfig
+	// Enforce makes the middleware answer the rejected cross-origin requests (actual and preflights)
+	// instead of passing them to the next handler without the CORS headers
+	Enforce bool
+	// EnforceStatus is the status code of the rejections (403 by default)
+	EnforceStatus int
+	// EnforceBody replaces the JSON error returned with the rejections
+	EnforceBody string
+	// AllowPrivateNetworkOrigins restricts the private network access to the listed origins. Setting
+	// it enables the private network access even if AllowPrivateNetwork is not set
+	AllowPrivateNetworkOrigins []string
+	// PrivateNetworkAccessName is the device name returned to the private network access preflights,
+	// shown by the browsers in the permission prompts
+	PrivateNetworkAccessName string
+	// PrivateNetworkAccessID is the device identifier returned to the private network access
+	// preflights, as six pairs of hexadecimal digits separated by colons
+	PrivateNetworkAccessID string
+	// AllowNullOrigin accepts the null origin sent by sandboxed iframes, local files and some
+	// redirects. Unless it is listed in AllowOrigins, it is rejected even with a wildcard origin
+	AllowNullOrigin bool
+	// OriginValidator is the name of the registered OriginValidator deciding which origins are
+	// allowed. The allowed origins of the Config are used if it is empty
+	OriginValidator string
+	// OriginValidatorConfig holds the options of the origin validator
+	OriginValidatorConfig map[string]interface{}
+	// Tenants maps host patterns ("api.example.com" or "*.example.com") to the policies of the tenants
+	// served under them. The policies are merged over the rest of the options, key by key
+	Tenants map[string]Config
+	// TenantHeader is the header holding the host used to select the tenant. The Host of the request
+	// is used if it is empty or the request does not have it
+	TenantHeader string
+	// TenantFallback decides what to do with the requests of unknown hosts: TenantFallbackDefault (the
+	// default) applies the rest of the options and TenantFallbackReject rejects all their origins
+	TenantFallback string
+	// Admin enables the admin handler, inspecting and updating the live policy at runtime
+	Admin *AdminConfig
+
+	// inherited lists the options of a tenant taken from the rest of the options
+	inherited []string
+}
+
+// knownKeys lists all the options accepted in the CORS namespace
+var knownKeys = []string{
+	"allow_origins",
+	"allow_origins_regex",
+	"allow_origins_patterns",
+	"allow_origins_file",
+	"allow_origins_file_interval",
+	"allow_methods",
+	"allow_headers",
+	"always_allow_headers",
+	"expose_headers",
+	"allow_credentials",
+	"allow_private_network",
+	"options_passthrough",
+	"options_success_status",
+	"max_age",
+	"debug",
+	"compat",
+	"strict",
+	"allow_insecure",
+	"audit",
+	"enforce",
+	"enforce_status",
+	"enforce_body",
+	"allow_private_network_origins",
+	"private_network_access_name",
+	"private_network_access_id",
+	"allow_null_origin",
+	"origin_validator",
+	"origin_validator_config",
+	"tenants",
+	"tenant_header",
+	"tenant_fallback",
+	"admin",
 }
 
 // ConfigGetter implements the config.ConfigGetter interface. It parses the extra config an allowed
 // origin must be defined, the rest of the options will use a default if not defined.
+//
+// ConfigGetter is kept for backwards compatibility: values with an unexpected type are silently
+// ignored. Use ParseConfig to get the details of every invalid value.
 func ConfigGetter(e config.ExtraConfig) interface{} {
 	v, ok := e[Namespace]
 	if !ok {
 		return nil
 	}
 
-	tmp, ok := v.(map[string]interface{})
-	if !ok {
+	if _, ok := v.(map[string]interface{}); !ok {
 		return nil
 	}
 
-	cfg := Config{}
-	cfg.AllowOrigins = getList(tmp, "allow_origins")
-	cfg.AllowMethods = getList(tmp, "allow_methods")
-	cfg.AllowHeaders = getList(tmp, "allow_headers")
-	cfg.ExposeHeaders = getList(tmp, "expose_headers")
-
-	if allowCredentials, ok := tmp["allow_credentials"]; ok {
-		if v, ok := allowCredentials.(bool); ok {
-			cfg.AllowCredentials = v
-		}
+	cfg, _ := ParseConfig(e)
+	return cfg
+}
+
+// ParseConfig parses the CORS namespace of the extra config. It returns ErrNoConfig if the namespace
+// is not present. Otherwise, the returned Config contains all the valid values and the error, if any,
+// is a ValidationErrors listing every value that could not be parsed or violates the Schema. When the
+// strict mode is enabled, the unknown keys are reported too.
+func ParseConfig(e config.ExtraConfig) (Config, error) {
+	v, ok := e[Namespace]
+	if !ok {
+		return Config{}, ErrNoConfig
 	}
 
-	if debug, ok := tmp["debug"]; ok {
-		v, ok := debug.(bool)
-		cfg.Debug = ok && v
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return Config{}, ValidationErrors{{
+			Path:     Namespace,
+			Got:      jsonType(v),
+			Expected: "object",
+		}}
 	}
 
-	if allowPrivateNetwork, ok := tmp["allow_private_network"]; ok {
-		v, ok := allowPrivateNetwork.(bool)
-		cfg.AllowPrivateNetwork = ok && v
+	cfg, errs := parseNamespace(tmp)
+	if schemaErrs, ok := ValidateSchema(e).(ValidationErrors); ok {
+		errs = mergeSchemaErrors(errs, schemaErrs, cfg.Strict)
 	}
 
-	if optionsPassthrough, ok := tmp["options_passthrough"]; ok {
-		v, ok := optionsPassthrough.(bool)
-		cfg.OptionsPassthrough = ok && v
+	if len(errs) > 0 {
+		return cfg, errs
 	}
+	return cfg, nil
+}
 
-	if optionsSuccessStatus, ok := tmp["options_success_status"]; ok {
-		if v, ok := optionsSuccessStatus.(float64); ok {
-			cfg.OptionsSuccessStatus = int(v)
+// parseNamespace parses the options of the namespace, including the ones accepted only at its top
+// level, without checking them against the schema
+func parseNamespace(tmp map[string]interface{}) (Config, ValidationErrors) {
+	p := newParser(tmp)
+	cfg := parseConfig(p, knownKeys)
+	cfg.TenantHeader = p.string("tenant_header")
+	cfg.TenantFallback = p.string("tenant_fallback")
+	if cfg.TenantFallback != "" && cfg.TenantFallback != TenantFallbackDefault && cfg.TenantFallback != TenantFallbackReject {
+		p.fail("tenant_fallback", p.path("tenant_fallback"), cfg.TenantFallback, "tenant fallback",
+			fmt.Errorf("unknown fallback %q", cfg.TenantFallback))
+		cfg.TenantFallback = ""
+	}
+	if tenants, ok := p.object("tenants"); ok {
+		cfg.Tenants = parseTenants(tenants, tmp)
+	}
+	if admin, ok := p.object("admin"); ok {
+		cfg.Admin = parseAdminConfig(admin)
+		if cfg.Strict {
+			admin.unknownKeys(adminKeys)
 		}
 	}
+	return cfg, *p.errs
+}
+
+// parseConfig parses the options of a policy. In strict mode, the keys out of the known list are
+// reported.
+func parseConfig(p *parser, known []string) Config {
+	cfg := Config{}
+	cfg.AllowOrigins = p.originList("allow_origins")
+	cfg.AllowOriginsRegex = p.regexpList("allow_origins_regex")
+	cfg.AllowOriginsPatterns = p.patternList("allow_origins_patterns")
+	cfg.AllowOriginsFile = p.string("allow_origins_file")
+	cfg.AllowOriginsFileInterval = p.duration("allow_origins_file_interval")
+	cfg.AllowMethods, cfg.AutoMethods = p.listOrAuto("allow_methods")
+	cfg.AllowHeaders, cfg.AutoHeaders = p.listOrAuto("allow_headers")
+	cfg.AlwaysAllowHeaders = p.list("always_allow_headers")
+	cfg.ExposeHeaders = p.list("expose_headers")
+	cfg.AllowCredentials = p.bool("allow_credentials")
+	cfg.Debug = p.bool("debug")
+	cfg.AllowPrivateNetwork = p.bool("allow_private_network")
+	cfg.OptionsPassthrough = p.bool("options_passthrough")
+	cfg.OptionsSuccessStatus = p.int("options_success_status")
+	if cfg.OptionsSuccessStatus != 0 && (cfg.OptionsSuccessStatus < 100 || cfg.OptionsSuccessStatus > 599) {
+		p.fail("options_success_status", p.path("options_success_status"), cfg.OptionsSuccessStatus, "status code",
+			fmt.Errorf("%d is not between 100 and 599", cfg.OptionsSuccessStatus))
+		cfg.OptionsSuccessStatus = 0
+	}
+	cfg.MaxAge = p.duration("max_age")
+	cfg.Compat = p.string("compat")
+	if cfg.Compat != "" && cfg.Compat != CompatGin {
+		p.fail("compat", p.path("compat"), cfg.Compat, "compatibility mode", fmt.Errorf("unknown mode %q", cfg.Compat))
+		cfg.Compat = ""
+	}
+	cfg.Strict = p.bool("strict")
+	cfg.AllowInsecure = p.bool("allow_insecure")
+	audit, hasAudit := p.object("audit")
+	if hasAudit {
+		cfg.Audit = parseAuditConfig(audit)
+	}
+	cfg.Enforce = p.bool("enforce")
+	cfg.EnforceStatus = p.int("enforce_status")
+	if cfg.EnforceStatus != 0 && (cfg.EnforceStatus < 400 || cfg.EnforceStatus > 599) {
+		p.fail("enforce_status", p.path("enforce_status"), cfg.EnforceStatus, "error status code",
+			fmt.Errorf("%d is not a client or server error", cfg.EnforceStatus))
+		cfg.EnforceStatus = 0
+	}
+	cfg.EnforceBody = p.string("enforce_body")
+	cfg.AllowPrivateNetworkOrigins = p.originList("allow_private_network_origins")
+	cfg.PrivateNetworkAccessName = p.string("private_network_access_name")
+	if cfg.PrivateNetworkAccessName != "" && !validPrivateNetworkAccessName(cfg.PrivateNetworkAccessName) {
+		p.fail("private_network_access_name", p.path("private_network_access_name"), cfg.PrivateNetworkAccessName,
+			"device name", errors.New("up to 248 lowercase letters, digits, '_', '-' or '.' are allowed"))
+		cfg.PrivateNetworkAccessName = ""
+	}
+	cfg.PrivateNetworkAccessID = p.string("private_network_access_id")
+	if cfg.PrivateNetworkAccessID != "" && !validPrivateNetworkAccessID(cfg.PrivateNetworkAccessID) {
+		p.fail("private_network_access_id", p.path("private_network_access_id"), cfg.PrivateNetworkAccessID,
+			"device id", errors.New(`six pairs of hexadecimal digits separated by colons (Ex: "01:23:45:67:89:0A") are required`))
+		cfg.PrivateNetworkAccessID = ""
+	}
+	cfg.AllowNullOrigin = p.bool("allow_null_origin")
+	cfg.OriginValidator = p.string("origin_validator")
+	if v, ok := p.object("origin_validator_config"); ok {
+		cfg.OriginValidatorConfig = v.data
+	}
 
-	if maxAge, ok := tmp["max_age"]; ok {
-		if d, err := time.ParseDuration(maxAge.(string)); err == nil {
-			cfg.MaxAge = d
+	if cfg.Strict {
+		p.unknownKeys(known)
+		if hasAudit {
+			audit.unknownKeys(auditKeys)
 		}
 	}
 	return cfg
 }
 
-func getList(data map[string]interface{}, name string) []string {
-	var out []string
-	if vs, ok := data[name]; ok {
-		if v, ok := vs.([]interface{}); ok {
-			for _, s := range v {
-				if j, ok := s.(string); ok {
-					out = append(out, j)
+// Load parses the CORS namespace as ParseConfig does, logging every problem found. Invalid values are
+// ignored and logged as warnings, one by one, unless the strict mode is enabled: in that case, the
+// errors are logged and returned, so the middleware should not be built. The invalid values of the
+// options restricting the allowed origins are always returned: ignoring them could leave the policy
+// open to every origin. The parsed configuration is then linted: the findings with error severity are
+// returned wrapping ErrInsecureConfig unless the allow_insecure override is enabled, and the rest are
+// logged as warnings.
+func Load(e config.ExtraConfig, l logging.Logger) (Config, error) {
+	if l == nil {
+		l = logging.NoOp
+	}
+
+	cfg, err := ParseConfig(e)
+	if err == ErrNoConfig {
+		return cfg, err
+	}
+
+	if err != nil {
+		if _, ok := e[Namespace].(map[string]interface{}); !ok {
+			l.Error(logPrefix, err.Error())
+			return cfg, err
+		}
+
+		if cfg.Strict {
+			l.Error(logPrefix, "Strict validation failed:", err.Error())
+			return cfg, err
+		}
+
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			l.Warning(logPrefix, err.Error())
+		}
+		if errs.restrictOrigins() {
+			for _, fe := range errs {
+				if fe.restrictsOrigins() {
+					l.Error(logPrefix, "Invalid allowed origins:", fe.Error())
+					continue
 				}
+				l.Error(logPrefix, "Invalid value:", fe.Error())
 			}
+			return cfg, err
+		}
+		for _, fe := range errs {
+			l.Warning(logPrefix, "Ignoring the invalid value:", fe.Error())
 		}
 	}
-	return out
+
+	var insecure []string
+	for _, f := range cfg.Lint() {
+		if f.Severity == SeverityError && !cfg.AllowInsecure {
+			l.Error(logPrefix, f.String())
+			insecure = append(insecure, f.Rule)
+			continue
+		}
+		l.Warning(logPrefix, f.String())
+	}
+	if len(insecure) > 0 {
+		return cfg, fmt.Errorf("%w: %s", ErrInsecureConfig, strings.Join(insecure, ", "))
+	}
+
+	return cfg, nil
 }
+
+const logPrefix = "[CORS]"
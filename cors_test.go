@@ -1,9 +1,17 @@
 package cors
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/luraproject/lura/v3/logging"
 )
 
 func TestConfigGetter(t *testing.T) {
@@ -94,3 +102,249 @@ func TestEmptyConfiguration(t *testing.T) {
 		t.Errorf("The configuration should not be empty: %v\n", v)
 	}
 }
+
+func TestParseConfig_invalidValues(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "http://localhost", 42, "http://www.example.com" ],
+			"expose_headers": "Content-Type",
+			"allow_credentials": "true",
+			"options_success_status": "204",
+			"max_age": 3600
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	cfg, err := ParseConfig(sampleCfg)
+	if err == nil {
+		t.Error("an error was expected")
+		return
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("unexpected error type: %T", err)
+		return
+	}
+
+	expected := []FieldError{
+		{Key: "allow_origins", Path: "security/cors.allow_origins[1]", Got: "number", Expected: "string"},
+		{Key: "expose_headers", Path: "security/cors.expose_headers", Got: "string", Expected: "array of strings"},
+		{Key: "allow_credentials", Path: "security/cors.allow_credentials", Got: "string", Expected: "boolean"},
+		{Key: "options_success_status", Path: "security/cors.options_success_status", Got: "string", Expected: "integer"},
+		{Key: "max_age", Path: "security/cors.max_age", Got: "number", Expected: "duration string"},
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("unexpected number of errors. have %d, want %d: %v", len(errs), len(expected), err)
+		return
+	}
+	for i, e := range expected {
+		if *errs[i] != e {
+			t.Errorf("unexpected error #%d. have %+v, want %+v", i, *errs[i], e)
+		}
+	}
+
+	if len(cfg.AllowOrigins) != 2 {
+		t.Errorf("the valid origins should be kept: %v", cfg.AllowOrigins)
+	}
+	if cfg.MaxAge != 0 {
+		t.Errorf("unexpected max age: %v", cfg.MaxAge)
+	}
+
+	if _, ok := ConfigGetter(sampleCfg).(Config); !ok {
+		t.Error("ConfigGetter should ignore the invalid values")
+	}
+}
+
+func TestParseConfig_invalidDuration(t *testing.T) {
+	sampleCfg := map[string]interface{}{Namespace: map[string]interface{}{"max_age": "12 hours"}}
+	_, err := ParseConfig(sampleCfg)
+	if err == nil {
+		t.Error("an error was expected")
+		return
+	}
+	want := "1 invalid value(s) in security/cors: " +
+		`security/cors.max_age: invalid duration string: time: unknown unit " hours" in duration "12 hours"`
+	if msg := err.Error(); msg != want {
+		t.Errorf("unexpected error message: %s", msg)
+	}
+}
+
+func TestParseConfig_noConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]interface{}{}); err != ErrNoConfig {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err := ParseConfig(map[string]interface{}{Namespace: "test"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Expected != "object" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseConfig_strict(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"strict": true,
+			"allowed_origins": [ "http://localhost" ],
+			"expose_header": [ "Content-Type" ],
+			"something_else": true
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	_, err := ParseConfig(sampleCfg)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if len(errs) != 3 {
+		t.Errorf("unexpected number of errors: %v", err)
+		return
+	}
+	for i, msg := range []string{
+		`security/cors.allowed_origins: unknown key, did you mean "allow_origins"?`,
+		`security/cors.expose_header: unknown key, did you mean "expose_headers"?`,
+		`security/cors.something_else: unknown key`,
+	} {
+		if !errors.Is(errs[i], ErrUnknownKey) {
+			t.Errorf("error #%d should wrap ErrUnknownKey", i)
+		}
+		if errs[i].Error() != msg {
+			t.Errorf("unexpected error #%d: %s", i, errs[i].Error())
+		}
+	}
+
+	delete(sampleCfg[Namespace].(map[string]interface{}), "strict")
+	if _, err := ParseConfig(sampleCfg); err != nil {
+		t.Errorf("unknown keys should be ignored out of the strict mode: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+
+	sampleCfg := map[string]interface{}{Namespace: map[string]interface{}{"max_age": 3600}}
+	if _, err := Load(sampleCfg, l); err != nil {
+		t.Errorf("invalid values should not fail out of the strict mode: %v", err)
+	}
+	if strings.Count(buf.String(), "max_age") != 1 ||
+		!strings.Contains(buf.String(), "WARNING: [CORS] Ignoring the invalid value: security/cors.max_age: got number, expected duration string") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	buf.Reset()
+	sampleCfg = map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins": []interface{}{"https://www.example.com/"},
+		"max_age":       3600,
+	}}
+	if _, err := Load(sampleCfg, l); err == nil {
+		t.Error("an error was expected")
+	}
+	for _, msg := range []string{
+		"ERROR: [CORS] Invalid allowed origins: security/cors.allow_origins[0]: invalid origin: a path (or a trailing slash) is not allowed",
+		"ERROR: [CORS] Invalid value: security/cors.max_age: got number, expected duration string",
+	} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("%q not found in the log: %s", msg, buf.String())
+		}
+	}
+	if n := strings.Count(buf.String(), "max_age"); n != 1 {
+		t.Errorf("every invalid value should be logged once: %s", buf.String())
+	}
+
+	buf.Reset()
+	sampleCfg = map[string]interface{}{Namespace: map[string]interface{}{"max_age": 3600, "strict": true}}
+	if _, err := Load(sampleCfg, l); err == nil {
+		t.Error("an error was expected")
+	}
+	if !strings.Contains(buf.String(), "ERROR: [CORS] Strict validation failed: 1 invalid value(s) in security/cors") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestParseConfig_autoMethods(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{"allow_methods": "auto"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !cfg.AutoMethods || len(cfg.AllowMethods) != 0 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	_, err = ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{"allow_methods": "GET"}})
+	want := "1 invalid value(s) in security/cors: " +
+		`security/cors.allow_methods: invalid array of strings or "auto": unknown keyword "GET"`
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseConfig_privateNetworkAccess(t *testing.T) {
+	_, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"private_network_access_name": "Office Printer",
+		"private_network_access_id":   "01:23:45:67:89",
+	}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if errs[0].Key != "private_network_access_name" || errs[1].Key != "private_network_access_id" {
+		t.Errorf("unexpected errors: %v", err)
+	}
+
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"private_network_access_name": "office-printer.local",
+		"private_network_access_id":   "01:23:45:67:89:0a",
+	}})
+	if err != nil {
+		t.Error(err)
+	}
+	if cfg.PrivateNetworkAccessName != "office-printer.local" || cfg.PrivateNetworkAccessID != "01:23:45:67:89:0a" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestParseConfig_optionsSuccessStatus(t *testing.T) {
+	for _, status := range []int{50, 600} {
+		e := map[string]interface{}{Namespace: map[string]interface{}{
+			"allow_origins":          []interface{}{"https://www.example.com"},
+			"options_success_status": status,
+		}}
+		cfg, err := ParseConfig(e)
+		want := "1 invalid value(s) in security/cors: " +
+			fmt.Sprintf("security/cors.options_success_status: invalid status code: %d is not between 100 and 599", status)
+		if err == nil || err.Error() != want {
+			t.Errorf("%d: unexpected error: %v", status, err)
+		}
+		if cfg.OptionsSuccessStatus != 0 {
+			t.Errorf("%d: the invalid status should be dropped: %d", status, cfg.OptionsSuccessStatus)
+		}
+
+		cfg, err = Load(e, nil)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", status, err)
+			continue
+		}
+		p, err := NewPolicy(cfg, nil)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", status, err)
+			continue
+		}
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodOptions, "https://example.com/foo", http.NoBody)
+		req.Header.Set("Origin", "https://www.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		p.Handler(http.NotFoundHandler()).ServeHTTP(res, req)
+		if res.Code != http.StatusNoContent {
+			t.Errorf("%d: unexpected status code: %d", status, res.Code)
+		}
+	}
+}
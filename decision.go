@@ -0,0 +1,220 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Reason explains why a cross-origin request was rejected
+type Reason string
+
+const (
+	// ReasonNone is the reason of the allowed requests
+	ReasonNone Reason = ""
+	// ReasonOrigin rejects the requests from an origin not allowed
+	ReasonOrigin Reason = "origin"
+	// ReasonMethod rejects the requests using (or requesting) a method not allowed
+	ReasonMethod Reason = "method"
+	// ReasonHeader rejects the preflights requesting a header not allowed
+	ReasonHeader Reason = "header"
+	// ReasonPrivateNetwork rejects the preflights requesting access to the private network when
+	// it is not allowed
+	ReasonPrivateNetwork Reason = "private_network"
+	// ReasonMalformedOrigin rejects the requests with an Origin header that is not a serialized
+	// origin (a scheme and a host with an optional port)
+	ReasonMalformedOrigin Reason = "malformed_origin"
+)
+
+// decision is the outcome of evaluating a cross-origin request against the policy, following the
+// same steps as rs/cors
+type decision struct {
+	preflight bool
+	origin    string
+	method    string
+	headers   string
+	allowed   bool
+	reason    Reason
+	// rule is the allowed origins entry matching the origin of the request
+	rule string
+	// detail explains the rejection of the origin, if the validator gave a reason
+	detail string
+	// steps records every check, only when the decision is explained
+	steps []Step
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// evaluate decides whether the request is allowed. Requests without an Origin header, or with the
+// origin of the request itself, are not cross-origin requests, so they are always allowed.
+func (p *Policy) evaluate(r *http.Request) decision {
+	return p.decide(r, false)
+}
+
+// decide evaluates the request, stopping at the first failed check unless it is explaining the
+// decision: then, all the checks are run and recorded, but the reason is still the first failure.
+func (p *Policy) decide(r *http.Request, explain bool) decision {
+	d := decision{
+		preflight: isPreflight(r),
+		origin:    r.Header.Get("Origin"),
+		method:    r.Method,
+	}
+	if d.preflight {
+		d.method = r.Header.Get("Access-Control-Request-Method")
+		d.headers = strings.Join(r.Header["Access-Control-Request-Headers"], ",")
+	}
+	if d.origin == "" {
+		d.allowed = true
+		return d
+	}
+	if !d.preflight && sameOrigin(r, d.origin) {
+		if explain {
+			d.steps = append(d.steps, Step{Check: StepOrigin, Value: d.origin, Allowed: true, Rule: "same origin as the request"})
+		}
+		d.allowed = true
+		return d
+	}
+	check := func(name, value string, ok bool, rule string, reason Reason) bool {
+		if explain {
+			d.steps = append(d.steps, Step{Check: name, Value: value, Allowed: ok, Rule: rule})
+		}
+		if !ok && d.reason == ReasonNone {
+			d.reason = reason
+		}
+		return ok || explain
+	}
+
+	if reason := p.checkOrigin(d.origin); reason != ReasonNone {
+		if !check(StepOrigin, d.origin, false, originRejections[reason], reason) {
+			return d
+		}
+	} else {
+		ok, rule := p.origins.ValidateOrigin(r, d.origin)
+		if ok {
+			d.rule = rule
+		} else {
+			d.detail = rule
+			if rule == "" {
+				rule = "not in the allowed origins"
+			}
+		}
+		if !check(StepOrigin, d.origin, ok, rule, ReasonOrigin) {
+			return d
+		}
+	}
+
+	ok := p.methodAllowed(d.method)
+	if !check(StepMethod, d.method, ok, p.methodRule(d.method, ok), ReasonMethod) {
+		return d
+	}
+
+	if d.preflight {
+		if reqHeaders, found := r.Header["Access-Control-Request-Headers"]; found {
+			ok := p.headersAllowed(reqHeaders, func(name string, ok bool, rule string) {
+				check(StepHeader, name, ok, rule, ReasonHeader)
+			})
+			if !ok && !explain {
+				return d
+			}
+		}
+		if r.Header.Get(headerRequestPrivateNetwork) == "true" {
+			ok := p.privateNetworkAllowed(d.origin)
+			if !check(StepPrivateNetwork, "true", ok, p.privateNetworkRule(ok), ReasonPrivateNetwork) {
+				return d
+			}
+		}
+	}
+	if explain {
+		rule := "allow_credentials"
+		if !p.cfg.AllowCredentials {
+			rule = "allow_credentials is disabled: the browsers hide the responses to credentialed requests"
+		}
+		d.steps = append(d.steps, Step{Check: StepCredentials, Allowed: p.cfg.AllowCredentials, Rule: rule})
+	}
+	d.allowed = d.reason == ReasonNone
+	return d
+}
+
+// sameOrigin reports whether the origin is the one of the request. Browsers send the Origin header
+// with the same-origin requests using methods other than GET and HEAD too.
+func sameOrigin(r *http.Request, origin string) bool {
+	scheme := r.URL.Scheme
+	switch {
+	case scheme != "":
+	case r.TLS != nil:
+		scheme = "https"
+	case r.Header.Get("X-Forwarded-Proto") != "":
+		scheme, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		scheme = strings.TrimSpace(scheme)
+	default:
+		scheme = "http"
+	}
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	self, err := NormalizeOrigin(scheme + "://" + host)
+	if err != nil {
+		return false
+	}
+	o, err := NormalizeOrigin(origin)
+	return err == nil && o == self
+}
+
+// originRejections describes the origins rejected before consulting the validator
+var originRejections = map[Reason]string{
+	ReasonOrigin:          "the null origin is not allowed",
+	ReasonMalformedOrigin: "malformed origin",
+}
+
+func (p *Policy) methodAllowed(method string) bool {
+	if method == http.MethodOptions {
+		return true
+	}
+	for _, m := range p.cfg.AllowMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) methodRule(method string, ok bool) string {
+	switch {
+	case !ok:
+		return "not in allow_methods"
+	case method == http.MethodOptions:
+		return "OPTIONS is always allowed"
+	}
+	return method
+}
+
+// headersAllowed checks the list of requested headers as rs/cors does: browsers send them in
+// lowercase, sorted and without duplicates, so any other list is rejected. The result for every
+// header is reported to the check function.
+func (p *Policy) headersAllowed(values []string, check func(name string, ok bool, rule string)) bool {
+	allowed := true
+	last := ""
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			ok, rule := true, name
+			switch _, listed := p.headers[name]; {
+			case p.allHeaders:
+				rule = "*"
+			case !listed:
+				ok, rule = false, "not in allow_headers"
+			case name <= last:
+				ok, rule = false, "the requested headers must be lowercase, sorted and without duplicates"
+			}
+			check(name, ok, rule)
+			allowed = allowed && ok
+			last = name
+		}
+	}
+	return allowed
+}
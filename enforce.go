@@ -0,0 +1,40 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+var rejectionMessages = map[Reason]string{
+	ReasonOrigin:          "origin not allowed",
+	ReasonMethod:          "method not allowed",
+	ReasonHeader:          "header not allowed",
+	ReasonPrivateNetwork:  "private network access not allowed",
+	ReasonMalformedOrigin: "malformed origin",
+}
+
+// reject answers a cross-origin request rejected in enforce mode. Unless a custom body is configured,
+// the response is a JSON error with the message and the status code, as the rest of the gateway errors.
+func (p *Policy) reject(w http.ResponseWriter, d decision) {
+	h := w.Header()
+	if d.preflight {
+		h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	} else {
+		h.Add("Vary", "Origin")
+	}
+
+	body := []byte(p.cfg.EnforceBody)
+	if len(body) == 0 {
+		body, _ = json.Marshal(map[string]interface{}{
+			"error":  "CORS: " + rejectionMessages[d.reason],
+			"status": p.cfg.EnforceStatus,
+		})
+	}
+	if json.Valid(body) {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+	} else {
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(p.cfg.EnforceStatus)
+	w.Write(body)
+}
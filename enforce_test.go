@@ -0,0 +1,158 @@
+package cors
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicy_enforce(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     Config
+		headers map[string]string
+		status  int
+		body    string
+		called  bool
+	}{
+		{
+			name:   "no origin",
+			cfg:    Config{AllowOrigins: []string{"https://example.com"}, Enforce: true},
+			status: http.StatusOK,
+			called: true,
+		},
+		{
+			name:    "allowed",
+			cfg:     Config{AllowOrigins: []string{"https://example.com"}, Enforce: true},
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusOK,
+			called:  true,
+		},
+		{
+			name:    "same origin",
+			cfg:     Config{AllowOrigins: []string{"https://app.example.com"}, Enforce: true},
+			headers: map[string]string{"Origin": "https://Example.com:443"},
+			status:  http.StatusOK,
+			called:  true,
+		},
+		{
+			name:    "same host with another scheme",
+			cfg:     Config{AllowOrigins: []string{"https://app.example.com"}, Enforce: true},
+			headers: map[string]string{"Origin": "http://example.com"},
+			status:  http.StatusForbidden,
+			body:    `{"error":"CORS: origin not allowed","status":403}`,
+		},
+		{
+			name:    "not enforced",
+			cfg:     Config{AllowOrigins: []string{"https://example.com"}},
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			status:  http.StatusOK,
+			called:  true,
+		},
+		{
+			name:    "origin",
+			cfg:     Config{AllowOrigins: []string{"https://example.com"}, Enforce: true},
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			status:  http.StatusForbidden,
+			body:    `{"error":"CORS: origin not allowed","status":403}`,
+		},
+		{
+			name:    "preflight",
+			cfg:     Config{AllowOrigins: []string{"https://example.com"}, Enforce: true, OptionsPassthrough: true},
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE"},
+			status:  http.StatusForbidden,
+			body:    `{"error":"CORS: method not allowed","status":403}`,
+		},
+		{
+			name: "custom",
+			cfg: Config{
+				AllowOrigins:  []string{"https://example.com"},
+				Enforce:       true,
+				EnforceStatus: http.StatusUnauthorized,
+				EnforceBody:   "go away",
+			},
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			status:  http.StatusUnauthorized,
+			body:    "go away",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewPolicy(tc.cfg, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			called := false
+			h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+
+			method := "POST"
+			if tc.headers["Access-Control-Request-Method"] != "" {
+				method = "OPTIONS"
+			}
+			req, _ := http.NewRequest(method, "https://example.com/foo", http.NoBody)
+			for k, v := range tc.headers {
+				req.Header.Add(k, v)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if called != tc.called {
+				t.Errorf("unexpected call to the next handler: %v", called)
+			}
+			if res.Code != tc.status {
+				t.Errorf("unexpected status code: %d", res.Code)
+			}
+			if body := res.Body.String(); body != tc.body {
+				t.Errorf("unexpected body: %s", body)
+			}
+			if tc.status != http.StatusOK && res.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Error("the rejections should not allow the origin")
+			}
+		})
+	}
+}
+
+func TestParseConfig_enforce(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"enforce":        true,
+		"enforce_status": 200,
+	}})
+	want := "1 invalid value(s) in security/cors: " +
+		"security/cors.enforce_status: invalid error status code: 200 is not a client or server error"
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !cfg.Enforce || cfg.EnforceStatus != 0 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		target  string
+		tls     bool
+		proto   string
+		origin  string
+		allowed bool
+	}{
+		{"/foo", false, "", "http://example.com", true},
+		{"/foo", false, "", "https://example.com", false},
+		{"/foo", true, "", "https://example.com", true},
+		{"/foo", false, "https, http", "https://example.com", true},
+		{"https://example.com:8443/foo", false, "", "https://example.com:8443", true},
+		{"https://example.com:8443/foo", false, "", "https://example.com", false},
+		{"/foo", false, "", "null", false},
+	} {
+		req := httptest.NewRequest("POST", tc.target, http.NoBody)
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		if sameOrigin(req, tc.origin) != tc.allowed {
+			t.Errorf("%s (tls: %v, proto: %q) from %s: unexpected result", tc.target, tc.tls, tc.proto, tc.origin)
+		}
+	}
+}
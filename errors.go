@@ -0,0 +1,86 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownKey is wrapped by the FieldError reported for every unknown option when the strict
+// mode is enabled
+var ErrUnknownKey = errors.New("unknown key")
+
+// FieldError describes a value of the CORS namespace that could not be parsed
+type FieldError struct {
+	// Key is the name of the offending option
+	Key string
+	// Path locates the offending value under the extra config (e.g. security/cors.allow_origins[1])
+	Path string
+	// Got is the JSON type of the received value
+	Got string
+	// Expected describes the type the option requires
+	Expected string
+	// Err holds the parsing error when the type was right but the value was not
+	Err error
+	// Suggestion is the known key closest to an unknown one, if any
+	Suggestion string
+}
+
+func (e *FieldError) Error() string {
+	if errors.Is(e.Err, ErrUnknownKey) {
+		if e.Suggestion == "" {
+			return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+		}
+		return fmt.Sprintf("%s: %s, did you mean %q?", e.Path, e.Err.Error(), e.Suggestion)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: invalid %s: %s", e.Path, e.Expected, e.Err.Error())
+	}
+	return fmt.Sprintf("%s: got %s, expected %s", e.Path, e.Got, e.Expected)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// ValidationErrors is the list of problems found while parsing the CORS namespace
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d invalid value(s) in %s: %s", len(e), Namespace, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual errors, so they can be inspected with errors.Is and errors.As
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+func fieldPath(key string) string {
+	return Namespace + "." + key
+}
+
+// jsonType returns the name of the JSON type of a decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64, int32, uint, uint64, uint32:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
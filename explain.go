@@ -0,0 +1,104 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+)
+
+// The checks recorded in the steps of a Decision
+const (
+	StepOrigin         = "origin"
+	StepMethod         = "method"
+	StepHeader         = "header"
+	StepPrivateNetwork = "private_network"
+	StepCredentials    = "credentials"
+)
+
+// Step is a check run while evaluating a cross-origin request
+type Step struct {
+	// Check is the property checked: StepOrigin, StepMethod, StepHeader (once per requested header),
+	// StepPrivateNetwork or StepCredentials
+	Check string
+	// Value is the checked value of the request
+	Value string
+	// Allowed reports whether the check passed. The credentials are informative: without them the
+	// request is still allowed, but the browser hides the responses to the credentialed requests
+	Allowed bool
+	// Rule is the option or entry allowing the value, or the reason why it was not allowed
+	Rule string
+}
+
+// Decision explains how the policy handles a request
+type Decision struct {
+	Preflight bool
+	Origin    string
+	// Method is the method of the request or, for the preflights, the requested one
+	Method  string
+	Allowed bool
+	// Reason is the first failed check of the rejected requests
+	Reason Reason
+	// Steps lists all the checks in the order rs/cors runs them. It is empty for the requests
+	// without an Origin header, which are not cross-origin requests
+	Steps []Step
+	// Status is the status code of the response written by the middleware itself (the preflights
+	// and the enforced rejections), or zero if the request is passed to the next handler
+	Status int
+	// Headers are the headers added to the response by the middleware
+	Headers http.Header
+}
+
+// Explain evaluates the request with the same checks as the middleware and runs it through the
+// policy, without calling the next handler, to report the response headers. The request is not
+// collected by the metrics, the tracing nor the audit log, and its decision is not logged.
+func (p *Policy) Explain(r *http.Request) Decision {
+	if p.tenants != nil {
+		if tp, ok := p.tenants.policy(p.tenants.host(r)); ok {
+			return tp.Explain(r)
+		}
+		if p.tenants.fallback != nil {
+			return p.tenants.fallback.Explain(r)
+		}
+	}
+
+	d := p.decide(r, true)
+
+	quiet := *p
+	quiet.metrics, quiet.audit, quiet.log, quiet.tenants = nil, nil, nil, nil
+	w := &explainWriter{header: http.Header{}}
+	quiet.handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r.Clone(context.Background()))
+
+	return Decision{
+		Preflight: d.preflight,
+		Origin:    d.origin,
+		Method:    d.method,
+		Allowed:   d.allowed,
+		Reason:    d.reason,
+		Steps:     d.steps,
+		Status:    w.status,
+		Headers:   w.header,
+	}
+}
+
+// Explain explains the decision of the current policy
+func (lp *LivePolicy) Explain(r *http.Request) Decision {
+	return lp.Policy().Explain(r)
+}
+
+// explainWriter records the status code and the headers of a response, discarding its body
+type explainWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *explainWriter) Header() http.Header { return w.header }
+
+func (w *explainWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *explainWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
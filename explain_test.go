@@ -0,0 +1,141 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPolicy_Explain(t *testing.T) {
+	p, err := NewPolicy(Config{
+		AllowOrigins:               []string{"https://www.example.com"},
+		AllowOriginsPatterns:       []string{"https://*.example.org"},
+		AllowMethods:               []string{"GET", "PUT"},
+		AllowHeaders:               []string{"Authorization", "X-Tenant"},
+		AllowPrivateNetworkOrigins: []string{"https://www.example.com"},
+		AllowCredentials:           true,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		decision Decision
+	}{
+		{
+			name:     "same origin",
+			method:   "GET",
+			decision: Decision{Method: "GET", Allowed: true, Headers: http.Header{"Vary": {"Origin"}}},
+		},
+		{
+			name:   "allowed preflight",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.org",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "authorization,x-tenant",
+			},
+			decision: Decision{
+				Preflight: true,
+				Origin:    "https://app.example.org",
+				Method:    "PUT",
+				Allowed:   true,
+				Steps: []Step{
+					{Check: StepOrigin, Value: "https://app.example.org", Allowed: true, Rule: "https://*.example.org"},
+					{Check: StepMethod, Value: "PUT", Allowed: true, Rule: "PUT"},
+					{Check: StepHeader, Value: "authorization", Allowed: true, Rule: "authorization"},
+					{Check: StepHeader, Value: "x-tenant", Allowed: true, Rule: "x-tenant"},
+					{Check: StepCredentials, Allowed: true, Rule: "allow_credentials"},
+				},
+				Status: http.StatusNoContent,
+				Headers: http.Header{
+					"Vary": {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers, " +
+						"Access-Control-Request-Private-Network"},
+					"Access-Control-Allow-Origin":      {"https://app.example.org"},
+					"Access-Control-Allow-Methods":     {"PUT"},
+					"Access-Control-Allow-Headers":     {"authorization,x-tenant"},
+					"Access-Control-Allow-Credentials": {"true"},
+				},
+			},
+		},
+		{
+			name:   "rejected preflight",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                                 "https://app.example.org",
+				"Access-Control-Request-Method":          "DELETE",
+				"Access-Control-Request-Headers":         "x-debug,x-tenant",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			decision: Decision{
+				Preflight: true,
+				Origin:    "https://app.example.org",
+				Method:    "DELETE",
+				Reason:    ReasonMethod,
+				Steps: []Step{
+					{Check: StepOrigin, Value: "https://app.example.org", Allowed: true, Rule: "https://*.example.org"},
+					{Check: StepMethod, Value: "DELETE", Allowed: false, Rule: "not in allow_methods"},
+					{Check: StepHeader, Value: "x-debug", Allowed: false, Rule: "not in allow_headers"},
+					{Check: StepHeader, Value: "x-tenant", Allowed: true, Rule: "x-tenant"},
+					{Check: StepPrivateNetwork, Value: "true", Allowed: false, Rule: "not in allow_private_network_origins"},
+					{Check: StepCredentials, Allowed: true, Rule: "allow_credentials"},
+				},
+				Status: http.StatusNoContent,
+				Headers: http.Header{
+					"Vary": {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network"},
+				},
+			},
+		},
+		{
+			name:    "malformed origin",
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://www.example.com/"},
+			decision: Decision{
+				Origin: "https://www.example.com/",
+				Method: "GET",
+				Reason: ReasonMalformedOrigin,
+				Steps: []Step{
+					{Check: StepOrigin, Value: "https://www.example.com/", Allowed: false, Rule: "malformed origin"},
+					{Check: StepMethod, Value: "GET", Allowed: true, Rule: "GET"},
+					{Check: StepCredentials, Allowed: true, Rule: "allow_credentials"},
+				},
+				Headers: http.Header{"Vary": {"Origin"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "https://example.com/foo", http.NoBody)
+			for k, v := range tc.headers {
+				req.Header.Add(k, v)
+			}
+			d := p.Explain(req)
+			if !reflect.DeepEqual(d, tc.decision) {
+				t.Errorf("unexpected decision:\n%+v\nexpected:\n%+v", d, tc.decision)
+			}
+			if e := p.evaluate(req); e.allowed != d.Allowed || e.reason != d.Reason {
+				t.Errorf("the explanation does not match the evaluation: %+v", e)
+			}
+		})
+	}
+}
+
+func TestPolicy_Explain_enforce(t *testing.T) {
+	p, err := NewPolicy(Config{AllowOrigins: []string{"https://www.example.com"}, Enforce: true}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+	req.Header.Add("Origin", "https://evil.example.com")
+	d := p.Explain(req)
+	if d.Allowed || d.Reason != ReasonOrigin || d.Status != http.StatusForbidden {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+	if d.Headers.Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("unexpected headers: %v", d.Headers)
+	}
+}
@@ -9,45 +9,56 @@ import (
 	"github.com/krakend/krakend-cors/v3/mux"
 	"github.com/luraproject/lura/v3/config"
 	"github.com/luraproject/lura/v3/logging"
-	"github.com/rs/cors"
-	wrapper "github.com/rs/cors/wrapper/gin"
 )
 
 // New returns a gin.HandlerFunc with the CORS configuration provided in the ExtraConfig
 func New(e config.ExtraConfig) gin.HandlerFunc {
-	tmp := krakendcors.ConfigGetter(e)
-	if tmp == nil {
+	return NewWithLogger(e, nil)
+}
+
+// NewWithLogger returns a gin.HandlerFunc with the CORS configuration provided in the ExtraConfig,
+// reporting the configuration problems and the debug messages through the injected logger. It returns nil if the ExtraConfig
+// has no valid CORS configuration. The options customize the policy (e.g. to collect metrics).
+func NewWithLogger(e config.ExtraConfig, l logging.Logger, opts ...krakendcors.Option) gin.HandlerFunc {
+	cfg, err := krakendcors.Load(e, l)
+	if err != nil {
 		return nil
 	}
-	cfg, ok := tmp.(krakendcors.Config)
-	if !ok {
+
+	p, err := krakendcors.NewLivePolicy(cfg, l, opts...)
+	if err != nil {
+		if l != nil {
+			l.Error("[CORS]", err.Error())
+		}
 		return nil
 	}
+	return handlerFunc(p)
+}
 
-	if len(cfg.AllowOrigins) == 0 {
-		cfg.AllowOrigins = []string{"*"}
-	}
-	if len(cfg.AllowHeaders) == 0 {
-		cfg.AllowHeaders = []string{"*"}
-	}
-	// Maintain the old default value to not change behaviour
-	// the rs/cors new default is to return a 204
-	if cfg.OptionsSuccessStatus == 0 {
-		cfg.OptionsSuccessStatus = 200
-	}
+// ginCall carries the gin context of a request through the shared CORS handler
+type ginCall struct {
+	c      *gin.Context
+	called bool
+}
+
+type ginCallKey struct{}
 
-	return wrapper.New(cors.Options{
-		AllowedOrigins:       cfg.AllowOrigins,
-		AllowedMethods:       cfg.AllowMethods,
-		AllowedHeaders:       cfg.AllowHeaders,
-		ExposedHeaders:       cfg.ExposeHeaders,
-		AllowCredentials:     cfg.AllowCredentials,
-		AllowPrivateNetwork:  cfg.AllowPrivateNetwork,
-		OptionsPassthrough:   cfg.OptionsPassthrough,
-		OptionsSuccessStatus: cfg.OptionsSuccessStatus,
-		MaxAge:               int(cfg.MaxAge.Seconds()),
-		Debug:                cfg.Debug,
-	})
+// handlerFunc adapts the shared CORS policy to gin, aborting the chain when the policy does not call
+// the next handler (i.e. when it answers a preflight or an admin request). The CORS handler is built
+// once: its next handler resumes the gin chain of the request it gets from the request context.
+func handlerFunc(p *krakendcors.LivePolicy) gin.HandlerFunc {
+	h := p.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		call := r.Context().Value(ginCallKey{}).(*ginCall)
+		call.called = true
+		call.c.Next()
+	}))
+	return func(c *gin.Context) {
+		call := &ginCall{c: c}
+		h.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(c.Request.Context(), ginCallKey{}, call)))
+		if !call.called {
+			c.Abort()
+		}
+	}
 }
 
 // RunServer defines the interface of a function used by the KrakenD router to start the service
@@ -62,16 +73,19 @@ func NewRunServer(next RunServer) RunServer {
 
 // NewRunServerWithLogger returns a RunServer wrapping the injected one with a CORS middleware, so it is called before the
 // actual router checks the URL, method and other details related to selecting the proper handler for the
-// incoming request
-func NewRunServerWithLogger(next RunServer, l logging.Logger) RunServer {
+// incoming request. The CORS configuration of the endpoints is merged over the service one and applied to the
+// requests matching their path and method. The options are applied to all the policies. The dedicated server
+// of the admin handler, if any, is stopped when the injected RunServer returns.
+func NewRunServerWithLogger(next RunServer, l logging.Logger, opts ...krakendcors.Option) RunServer {
 	if l == nil {
 		l = logging.NoOp
 	}
 	return func(ctx context.Context, cfg config.ServiceConfig, handler http.Handler) error {
-		corsMw := mux.NewWithLogger(cfg.ExtraConfig, l)
+		corsMw := mux.NewServiceWithLogger(cfg, l, opts...)
 		if corsMw == nil {
 			return next(ctx, cfg, handler)
 		}
+		defer mux.Close(corsMw)
 		l.Debug("[SERVICE: Gin][CORS] Enabled CORS for all requests")
 		return next(ctx, cfg, corsMw.Handler(handler))
 	}
@@ -7,13 +7,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
 	"testing"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	krakendcors "github.com/krakend/krakend-cors/v3"
+	"github.com/krakend/krakend-cors/v3/mux"
 	"github.com/luraproject/lura/v3/config"
 	"github.com/luraproject/lura/v3/logging"
 )
@@ -49,8 +51,8 @@ func TestNew(t *testing.T) {
 	req.Header.Add("Access-Control-Request-Method", "GET")
 	req.Header.Add("Access-Control-Request-Headers", "origin")
 	e.ServeHTTP(res, req)
-	if res.Code != 200 {
-		t.Errorf("Invalid status code: %d should be 200", res.Code)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("Invalid status code: %d should be 204", res.Code)
 	}
 
 	assertHeaders(t, res.Header(), map[string]string{
@@ -62,6 +64,53 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestNew_concurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(map[string]interface{}{"security/cors": map[string]interface{}{"allow_origins": []interface{}{"http://foobar.com"}}}))
+	e.GET("/items/:id", func(c *gin.Context) { c.String(200, c.Param("id")) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := fmt.Sprintf("%d-%d", i, j)
+				req, _ := http.NewRequest("GET", "https://example.com/items/"+id, http.NoBody)
+				req.Header.Add("Origin", "http://foobar.com")
+				res := httptest.NewRecorder()
+				e.ServeHTTP(res, req)
+				if res.Body.String() != id || res.Header().Get("Access-Control-Allow-Origin") != "http://foobar.com" {
+					t.Errorf("unexpected response to %s: %d %s %v", id, res.Code, res.Body.String(), res.Header())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNew_rejectedOriginForwarded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(map[string]interface{}{"security/cors": map[string]interface{}{}}))
+	e.POST("/foo", func(c *gin.Context) { c.String(200, c.GetHeader("Origin")) })
+
+	for _, origin := range []string{"null", "https://example.com/path"} {
+		req, _ := http.NewRequest("POST", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		e.ServeHTTP(res, req)
+		if res.Body.String() != origin {
+			t.Errorf("the backend should get the original origin %q: %q", origin, res.Body.String())
+		}
+		if res.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("the origin %q should not be allowed", origin)
+		}
+	}
+}
+
 func TestAllowOriginWildcard(t *testing.T) {
 	sampleCfg := map[string]interface{}{}
 	serialized := []byte(`{ "security/cors": {
@@ -83,8 +132,8 @@ func TestAllowOriginWildcard(t *testing.T) {
 	req.Header.Add("Access-Control-Request-Method", "GET")
 	req.Header.Add("Access-Control-Request-Headers", "origin")
 	e.ServeHTTP(res, req)
-	if res.Code != 200 {
-		t.Errorf("Invalid status code: %d should be 200", res.Code)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("Invalid status code: %d should be 204", res.Code)
 	}
 
 	assertHeaders(t, res.Header(), map[string]string{
@@ -115,8 +164,8 @@ func TestAllowOriginEmpty(t *testing.T) {
 	req.Header.Add("Access-Control-Request-Method", "GET")
 	req.Header.Add("Access-Control-Request-Headers", "origin")
 	e.ServeHTTP(res, req)
-	if res.Code != 200 {
-		t.Errorf("Invalid status code: %d should be 200", res.Code)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("Invalid status code: %d should be 204", res.Code)
 	}
 
 	assertHeaders(t, res.Header(), map[string]string{
@@ -134,7 +183,7 @@ func ExampleNewRunServerWithLogger() {
 		return nil
 	}
 
-	buf := new(syncBuffer)
+	buf := new(bytes.Buffer)
 	l, _ := logging.NewLogger("DEBUG", buf, "")
 	corsRunServer := NewRunServerWithLogger(next, l)
 
@@ -186,7 +235,7 @@ func ExampleNewRunServerWithLogger() {
 	fmt.Println("'" + res.Body.String() + "'")
 
 	re := regexp.MustCompile(`(\d\d\d\d\/\d\d\/\d\d \d\d:\d\d:\d\d\s+)`)
-	fmt.Println(re.ReplaceAllString(buf.waitForLines(5), ""))
+	fmt.Println(re.ReplaceAllString(buf.String(), ""))
 
 	// output:
 	// 204
@@ -222,44 +271,12 @@ func ExampleNewRunServerWithLogger() {
 	// }
 	// 'Yeah'
 	// DEBUG: [SERVICE: Gin][CORS] Enabled CORS for all requests
+	// DEBUG: [CORS] decision=allowed preflight=true origin="http://foobar.com" method="GET" headers="origin" rule="http://foobar.com"
 	// DEBUG: [CORS] Handler: Preflight request
-	// DEBUG: [CORS] Preflight response headers: map[Access-Control-Allow-Headers:[origin] Access-Control-Allow-Methods:[GET] Access-Control-Allow-Origin:[http://foobar.com] Access-Control-Max-Age:[7200] Vary:[Origin, Access-Control-Request-Method, Access-Control-Request-Headers]]
+	// DEBUG: [CORS] Preflight response headers Access-Control-Allow-Headers="origin" Access-Control-Allow-Methods="GET" Access-Control-Allow-Origin="http://foobar.com" Access-Control-Max-Age="7200" Vary="Origin, Access-Control-Request-Method, Access-Control-Request-Headers"
+	// DEBUG: [CORS] decision=allowed preflight=false origin="http://foobar.com" method="GET" rule="http://foobar.com"
 	// DEBUG: [CORS] Handler: Actual request
-	// DEBUG: [CORS] Actual response added headers: map[Access-Control-Allow-Origin:[http://foobar.com] Vary:[Origin]]
-}
-
-// syncBuffer is the log sink for the examples exercising the debug output of
-// the CORS middleware. NewWithLogger forwards that output through a pipe read
-// by a background goroutine, so the writes it produces are concurrent with the
-// reads done by the test.
-type syncBuffer struct {
-	mu  sync.Mutex
-	buf bytes.Buffer
-}
-
-func (b *syncBuffer) Write(p []byte) (int, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	return b.buf.Write(p)
-}
-
-func (b *syncBuffer) String() string {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	return b.buf.String()
-}
-
-// waitForLines returns the contents of the buffer once it holds n lines, giving
-// the forwarding goroutine the chance to catch up. It gives up after a second
-// so a missing line shows up as a failed assertion instead of a hang.
-func (b *syncBuffer) waitForLines(n int) string {
-	for range 1000 {
-		if s := b.String(); strings.Count(s, "\n") >= n {
-			return s
-		}
-		time.Sleep(time.Millisecond)
-	}
-	return b.String()
+	// DEBUG: [CORS] Actual response added headers Access-Control-Allow-Origin="http://foobar.com" Vary="Origin"
 }
 
 var allHeaders = []string{
@@ -281,3 +298,400 @@ func assertHeaders(t *testing.T, resHeaders http.Header, expHeaders map[string]s
 		}
 	}
 }
+
+func TestNewWithLogger_strict(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, _ := logging.NewLogger("DEBUG", buf, "")
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"strict": true,
+			"max_age": 3600
+			}
+		}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	if corsMw := NewWithLogger(sampleCfg, logger); corsMw != nil {
+		t.Error("The corsMw should be nil.\n")
+	}
+	msg := "ERROR: [CORS] Strict validation failed: 1 invalid value(s) in security/cors: " +
+		"security/cors.max_age: got number, expected duration string"
+	if !strings.Contains(buf.String(), msg) {
+		t.Error("unexpected logged msg:", buf.String())
+	}
+}
+
+func TestAllowOriginsRegex(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins_regex": [ "https://pr-[0-9]+\\.preview\\.example\\.com" ]
+			}
+		}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	for origin, allowed := range map[string]string{
+		"https://pr-1234.preview.example.com": "https://pr-1234.preview.example.com",
+		"https://foobar.com":                  "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		e.ServeHTTP(res, req)
+
+		expected := map[string]string{
+			"Vary":                        "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			"Access-Control-Allow-Origin": allowed,
+		}
+		if allowed != "" {
+			expected["Access-Control-Allow-Methods"] = "GET"
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestNewRunServerWithLogger_endpoints(t *testing.T) {
+	var localHandler http.Handler
+	next := func(_ context.Context, _ config.ServiceConfig, handler http.Handler) error {
+		localHandler = handler
+		return nil
+	}
+
+	cfg := config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/public", Method: "GET"},
+			{
+				Endpoint: "/admin",
+				Method:   "GET",
+				ExtraConfig: map[string]interface{}{"security/cors": map[string]interface{}{
+					"allow_origins": []interface{}{"https://admin.example.com"},
+				}},
+			},
+		},
+	}
+	if err := NewRunServer(next)(context.Background(), cfg, http.NotFoundHandler()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for path, expected := range map[string]map[string]string{
+		"/public": {},
+		"/admin": {
+			"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			"Access-Control-Allow-Origin":  "https://admin.example.com",
+			"Access-Control-Allow-Methods": "GET",
+		},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com"+path, http.NoBody)
+		req.Header.Add("Origin", "https://admin.example.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		localHandler.ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestCompatGin(t *testing.T) {
+	sampleCfg := map[string]interface{}{"security/cors": map[string]interface{}{"compat": "gin"}}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+	req.Header.Add("Origin", "http://foobar.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	e.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("Invalid status code: %d should be 200", res.Code)
+	}
+}
+
+// TestFlavours checks that the mux and gin flavours answer the same requests with the same
+// status codes and headers
+func TestFlavours(t *testing.T) {
+	configs := map[string]string{
+		"defaults": `{}`,
+		"restricted": `{
+			"allow_origins": [ "http://foobar.com" ],
+			"allow_methods": [ "GET", "PUT" ],
+			"allow_headers": [ "X-Test" ],
+			"expose_headers": [ "X-Response" ],
+			"allow_credentials": true,
+			"allow_private_network": true,
+			"max_age": "1h"
+		}`,
+		"passthrough": `{ "options_passthrough": true, "options_success_status": 202 }`,
+		"compat":      `{ "compat": "gin" }`,
+		"patterns":    `{ "allow_origins_patterns": [ "http://*.foobar.com", "http://foobar.com:*" ] }`,
+	}
+	requests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+	}{
+		{"no origin", "GET", nil},
+		{"actual", "GET", map[string]string{"Origin": "http://foobar.com"}},
+		{"actual from another origin", "GET", map[string]string{"Origin": "http://example.com"}},
+		{"actual from a subdomain", "GET", map[string]string{"Origin": "http://www.foobar.com"}},
+		{"actual with a disallowed method", "DELETE", map[string]string{"Origin": "http://foobar.com"}},
+		{"preflight", "OPTIONS", map[string]string{
+			"Origin":                                 "http://foobar.com",
+			"Access-Control-Request-Method":          "PUT",
+			"Access-Control-Request-Headers":         "x-test",
+			"Access-Control-Request-Private-Network": "true",
+		}},
+		{"preflight with a disallowed header", "OPTIONS", map[string]string{
+			"Origin":                         "http://foobar.com",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "x-other",
+		}},
+		{"plain options", "OPTIONS", map[string]string{"Origin": "http://foobar.com"}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for name, cfg := range configs {
+		sampleCfg := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(`{ "security/cors": `+cfg+` }`), &sampleCfg); err != nil {
+			t.Error(err)
+			return
+		}
+
+		e := gin.New()
+		e.Use(NewWithLogger(sampleCfg, nil))
+		e.Handle("GET", "/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+		e.Handle("PUT", "/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+		e.Handle("DELETE", "/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+		e.Handle("OPTIONS", "/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+		muxHandler := mux.New(sampleCfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("Yeah"))
+		}))
+
+		for _, tc := range requests {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				ginRes, muxRes := httptest.NewRecorder(), httptest.NewRecorder()
+				for _, res := range []*httptest.ResponseRecorder{ginRes, muxRes} {
+					req, _ := http.NewRequest(tc.method, "https://example.com/foo", http.NoBody)
+					for k, v := range tc.headers {
+						req.Header.Add(k, v)
+					}
+					if res == ginRes {
+						e.ServeHTTP(res, req)
+					} else {
+						muxHandler.ServeHTTP(res, req)
+					}
+				}
+
+				if ginRes.Code != muxRes.Code {
+					t.Errorf("unexpected status codes. gin: %d, mux: %d", ginRes.Code, muxRes.Code)
+				}
+				if !reflect.DeepEqual(ginRes.Header(), muxRes.Header()) {
+					t.Errorf("unexpected headers.\ngin: %v\nmux: %v", ginRes.Header(), muxRes.Header())
+				}
+				if ginRes.Body.String() != muxRes.Body.String() {
+					t.Errorf("unexpected bodies. gin: %q, mux: %q", ginRes.Body.String(), muxRes.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestNewWithLogger_audit(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "https://example.com" ]
+			}
+		}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	buf := new(bytes.Buffer)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(NewWithLogger(sampleCfg, nil, krakendcors.WithAuditWriter(buf)))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	for _, origin := range []string{"https://example.com", "https://evil.example.com", "https://evil.example.com"} {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var rec krakendcors.AuditRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Errorf("unexpected audit log: %s", buf.String())
+		return
+	}
+	if rec.Origin != "https://evil.example.com" || rec.Path != "/foo" || rec.Reason != krakendcors.ReasonOrigin {
+		t.Errorf("unexpected audit record: %+v", rec)
+	}
+}
+
+func TestNew_enforce(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "https://example.com" ],
+			"enforce": true
+			}
+		}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	called := 0
+	e.GET("/foo", func(c *gin.Context) {
+		called++
+		c.String(200, "Yeah")
+	})
+
+	for origin, status := range map[string]int{
+		"":                         200,
+		"https://example.com":      200,
+		"https://evil.example.com": 403,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		if origin != "" {
+			req.Header.Add("Origin", origin)
+		}
+		e.ServeHTTP(res, req)
+		if res.Code != status {
+			t.Errorf("unexpected status code for %q: %d", origin, res.Code)
+		}
+	}
+	if called != 2 {
+		t.Errorf("unexpected number of calls to the handler: %d", called)
+	}
+}
+
+func TestAllowPrivateNetworkOrigins(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_private_network_origins": [ "http://intranet.example.com" ],
+			"private_network_access_name": "office-printer"
+			}
+		}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	for origin, allowed := range map[string]string{
+		"http://intranet.example.com": "true",
+		"http://foobar.com":           "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Access-Control-Request-Private-Network", "true")
+		e.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNoContent {
+			t.Errorf("Invalid status code: %d should be 204", res.Code)
+		}
+		if v := res.Header().Get("Access-Control-Allow-Private-Network"); v != allowed {
+			t.Errorf("unexpected private network access for %s: %q", origin, v)
+		}
+		if v := res.Header().Get("Private-Network-Access-Name"); (v != "") != (allowed != "") {
+			t.Errorf("unexpected device name for %s: %q", origin, v)
+		}
+	}
+}
+
+func TestOriginValidator(t *testing.T) {
+	krakendcors.RegisterOriginValidator("gin-test", func(_ krakendcors.Config, _ logging.Logger) (krakendcors.OriginValidator, error) {
+		return krakendcors.OriginValidatorFunc(func(r *http.Request, origin string) (bool, string) {
+			return origin == "https://"+r.Host, "same host"
+		}), nil
+	})
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": { "origin_validator": "gin-test" } }`)
+	json.Unmarshal(serialized, &sampleCfg)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	for origin, allowed := range map[string]string{
+		"https://example.com": "https://example.com",
+		"https://foobar.com":  "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		e.ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                        "Origin",
+			"Access-Control-Allow-Origin": allowed,
+		})
+	}
+}
+
+func TestNew_admin(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "https://www.example.com" ],
+			"admin": { "path": "/__cors", "token": "secret" }
+		}
+	}`)
+	json.Unmarshal(serialized, &sampleCfg)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(New(sampleCfg))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "https://example.com/__cors", strings.NewReader(`{"add":{"allow_origins":["https://partner.example.org"]}}`))
+	req.Header.Add("Authorization", "Bearer secret")
+	e.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d %s", res.Code, res.Body.String())
+		return
+	}
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+	req.Header.Add("Origin", "https://partner.example.org")
+	e.ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                        "Origin",
+		"Access-Control-Allow-Origin": "https://partner.example.org",
+	})
+}
+
+func TestExplain(t *testing.T) {
+	p, err := krakendcors.NewLivePolicy(krakendcors.Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		AllowMethods: []string{"GET"},
+		AllowHeaders: []string{"Authorization"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(handlerFunc(p))
+	e.GET("/foo", func(c *gin.Context) { c.String(200, "Yeah") })
+
+	for _, headers := range []map[string]string{
+		{"Origin": "https://www.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "authorization"},
+		{"Origin": "https://www.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "x-debug"},
+		{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"},
+	} {
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		res := httptest.NewRecorder()
+		e.ServeHTTP(res, req)
+
+		d := p.Explain(req)
+		if d.Status != res.Code || !reflect.DeepEqual(d.Headers, res.Header()) {
+			t.Errorf("the explanation does not match the response: %d %v, got %d %v", d.Status, d.Headers, res.Code, res.Header())
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package cors
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInsecureConfig is returned by Load when the security checks report errors and the
+// allow_insecure override is not enabled
+var ErrInsecureConfig = errors.New("insecure CORS configuration")
+
+// Severity classifies the findings of the security checks
+type Severity int
+
+const (
+	// SeverityWarning flags a risky setting that is allowed
+	SeverityWarning Severity = iota
+	// SeverityError flags a setting that blocks the middleware construction unless the
+	// allow_insecure override is enabled
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding describes an insecure setting detected in a Config
+type Finding struct {
+	Severity Severity
+	// Rule is the identifier of the check reporting the finding
+	Rule string
+	// Key is the option the finding is about (Ex: "allow_credentials" or "tenants.example.com.allow_origins")
+	Key     string
+	Message string
+}
+
+func (f Finding) String() string {
+	return f.Severity.String() + " [" + f.Rule + "] " + f.Message
+}
+
+// Lint checks the configuration for insecure combinations of options. An empty list of allowed
+// origins or headers is considered a wildcard, because that is the default applied by the
+// middleware constructors.
+func (c Config) Lint() []Finding {
+	var findings []Finding
+	add := func(s Severity, rule, key, msg string) {
+		findings = append(findings, Finding{Severity: s, Rule: rule, Key: key, Message: msg})
+	}
+
+	wildcardOrigin := c.wildcardOrigin()
+
+	if c.AllowCredentials && wildcardOrigin {
+		add(SeverityError, "credentials-wildcard-origin", "allow_credentials",
+			"allow_credentials with a wildcard origin lets any site perform authenticated requests")
+	}
+	wildcardHeaders := contains(c.AllowHeaders, "*") || contains(c.AlwaysAllowHeaders, "*") ||
+		(len(c.AllowHeaders) == 0 && !c.AutoHeaders)
+	if c.AllowCredentials && wildcardHeaders {
+		add(SeverityWarning, "credentials-wildcard-headers", "allow_headers",
+			"allow_credentials with a wildcard in allow_headers accepts any request header in authenticated requests")
+	}
+	wildcardPrivateNetwork := wildcardOrigin && len(c.AllowPrivateNetworkOrigins) == 0 ||
+		contains(c.AllowPrivateNetworkOrigins, "*")
+	if (c.AllowPrivateNetwork || len(c.AllowPrivateNetworkOrigins) > 0) && wildcardPrivateNetwork {
+		add(SeverityWarning, "private-network-wildcard-origin", "allow_private_network",
+			"allow_private_network with a wildcard origin lets any public site reach the private network")
+	}
+	if c.nullOriginAllowed() {
+		add(SeverityWarning, "null-origin", "allow_origins",
+			"the null origin is shared by sandboxed iframes and local files, so any page can forge it")
+	}
+
+	var plain, secure []string
+	for _, o := range c.AllowOrigins {
+		switch {
+		case strings.HasPrefix(o, "http://"):
+			plain = append(plain, o)
+		case strings.HasPrefix(o, "https://"):
+			secure = append(secure, o)
+		}
+	}
+	if len(plain) > 0 && len(secure) > 0 {
+		add(SeverityWarning, "mixed-scheme-origins", "allow_origins",
+			"the http origins "+strings.Join(plain, ", ")+" are allowed alongside https ones and can be spoofed by a network attacker")
+	}
+
+	for _, l := range []struct {
+		key    string
+		values []string
+	}{
+		{"allow_origins", c.AllowOrigins},
+		{"allow_origins_patterns", c.AllowOriginsPatterns},
+		{"allow_origins_regex", c.AllowOriginsRegex},
+		{"allow_methods", c.AllowMethods},
+		{"allow_headers", c.AllowHeaders},
+		{"always_allow_headers", c.AlwaysAllowHeaders},
+		{"expose_headers", c.ExposeHeaders},
+		{"allow_private_network_origins", c.AllowPrivateNetworkOrigins},
+	} {
+		if dups := duplicates(l.values); len(dups) > 0 {
+			add(SeverityWarning, "duplicate-entries", l.key, l.key+" lists "+strings.Join(dups, ", ")+" more than once")
+		}
+	}
+	for _, u := range c.unreachableOrigins() {
+		add(SeverityWarning, "unreachable-origins", u.key, u.msg)
+	}
+
+	hosts := make([]string, 0, len(c.Tenants))
+	for host := range c.Tenants {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		for _, f := range c.Tenants[host].Lint() {
+			f.Message = "tenant " + host + ": " + f.Message
+			f.Key = "tenants." + host + "." + f.Key
+			findings = append(findings, f)
+		}
+	}
+
+	return findings
+}
+
+// wildcardOrigin reports whether any origin is allowed, explicitly or by default
+func (c Config) wildcardOrigin() bool {
+	return (len(c.AllowOrigins) == 0 && len(c.AllowOriginsRegex) == 0 && len(c.AllowOriginsPatterns) == 0 &&
+		c.AllowOriginsFile == "" && c.OriginValidator == "") ||
+		contains(c.AllowOrigins, "*")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicates returns the entries listed more than once, ignoring the case
+func duplicates(values []string) []string {
+	seen := make(map[string]int, len(values))
+	var dups []string
+	for _, v := range values {
+		k := strings.ToLower(v)
+		seen[k]++
+		if seen[k] == 2 {
+			dups = append(dups, v)
+		}
+	}
+	return dups
+}
+
+type unreachableOrigin struct {
+	key string
+	msg string
+}
+
+// unreachableOrigins describes the allowed origins entries that never decide whether an origin is
+// allowed, because a broader entry already matches all of them
+func (c Config) unreachableOrigins() []unreachableOrigin {
+	if contains(c.AllowOrigins, "*") {
+		var others []string
+		for _, o := range c.AllowOrigins {
+			if o != "*" && o != nullOrigin {
+				others = append(others, o)
+			}
+		}
+		others = append(append(others, c.AllowOriginsPatterns...), c.AllowOriginsRegex...)
+		if len(others) == 0 {
+			return nil
+		}
+		return []unreachableOrigin{{"allow_origins", "the wildcard origin already allows " + strings.Join(others, ", ")}}
+	}
+
+	var out []unreachableOrigin
+	patterns := make([]*originPattern, 0, len(c.AllowOriginsPatterns))
+	for _, raw := range c.AllowOriginsPatterns {
+		if p, err := compileOriginPattern(raw); err == nil {
+			patterns = append(patterns, p)
+		}
+	}
+	regexps := make(map[string]*regexp.Regexp, len(c.AllowOriginsRegex))
+	for _, expr := range c.AllowOriginsRegex {
+		if re, err := compileOriginRegex(expr); err == nil {
+			regexps[expr] = re
+		}
+	}
+
+	matcher := newPatternMatcher(patterns)
+	seen := map[string]bool{}
+	for _, o := range c.AllowOrigins {
+		if o == nullOrigin || strings.Contains(o, "*") || seen[o] {
+			continue
+		}
+		seen[o] = true
+		if by, ok := matcher.match(o); ok {
+			out = append(out, unreachableOrigin{"allow_origins", o + " is already allowed by " + by})
+			continue
+		}
+		for _, expr := range c.AllowOriginsRegex {
+			if re, ok := regexps[expr]; ok && re.MatchString(o) {
+				out = append(out, unreachableOrigin{"allow_origins", o + " is already allowed by " + expr})
+				break
+			}
+		}
+	}
+
+	for _, p := range patterns {
+		for _, q := range patterns {
+			if q.covers(p) {
+				out = append(out, unreachableOrigin{"allow_origins_patterns", p.raw + " is already allowed by " + q.raw})
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package cors
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+func TestConfig_Lint(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   Config
+		rules []string
+	}{
+		{
+			name: "secure",
+			cfg: Config{
+				AllowOrigins:     []string{"https://example.com"},
+				AllowHeaders:     []string{"Authorization"},
+				AllowCredentials: true,
+			},
+		},
+		{
+			name:  "credentials with default origins and headers",
+			cfg:   Config{AllowCredentials: true},
+			rules: []string{"error [credentials-wildcard-origin]", "warning [credentials-wildcard-headers]"},
+		},
+		{
+			name: "credentials with wildcard origin",
+			cfg: Config{
+				AllowOrigins:     []string{"https://example.com", "*"},
+				AllowHeaders:     []string{"Authorization"},
+				AllowCredentials: true,
+			},
+			rules: []string{"error [credentials-wildcard-origin]", "warning [unreachable-origins]"},
+		},
+		{
+			name:  "private network with wildcard origin",
+			cfg:   Config{AllowPrivateNetwork: true},
+			rules: []string{"warning [private-network-wildcard-origin]"},
+		},
+		{
+			name: "private network restricted to some origins",
+			cfg: Config{
+				AllowPrivateNetworkOrigins: []string{"https://intranet.example.com"},
+			},
+		},
+		{
+			name:  "null origin and mixed schemes",
+			cfg:   Config{AllowOrigins: []string{"https://example.com", "http://example.com", "null"}},
+			rules: []string{"warning [null-origin]", "warning [mixed-scheme-origins]"},
+		},
+		{
+			name: "duplicate entries",
+			cfg: Config{
+				AllowOrigins: []string{"https://example.com", "https://example.com"},
+				AllowMethods: []string{"GET", "get"},
+				AllowHeaders: []string{"Authorization"},
+			},
+			rules: []string{"warning [duplicate-entries] allow_origins lists https://example.com", "warning [duplicate-entries] allow_methods lists get"},
+		},
+		{
+			name: "unreachable origins",
+			cfg: Config{
+				AllowOrigins:         []string{"https://www.example.com", "https://app.example.org", "https://example.com"},
+				AllowOriginsPatterns: []string{"https://*.example.com", "https://*.api.example.com", "http://localhost:*", "http://localhost:3000"},
+				AllowOriginsRegex:    []string{`https://[a-z]+\.example\.org`},
+				AllowHeaders:         []string{"Authorization"},
+			},
+			rules: []string{
+				"warning [unreachable-origins] https://www.example.com is already allowed by https://*.example.com",
+				"warning [unreachable-origins] https://app.example.org is already allowed by https://[a-z]+\\.example\\.org",
+				"warning [unreachable-origins] https://*.api.example.com is already allowed by https://*.example.com",
+				"warning [unreachable-origins] http://localhost:3000 is already allowed by http://localhost:*",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := tc.cfg.Lint()
+			if len(findings) != len(tc.rules) {
+				t.Errorf("unexpected findings: %v", findings)
+				return
+			}
+			for i, f := range findings {
+				if !strings.HasPrefix(f.String(), tc.rules[i]) {
+					t.Errorf("unexpected finding #%d: %s", i, f.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_insecure(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+
+	sampleCfg := map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins":     []interface{}{"*"},
+		"allow_headers":     []interface{}{"Authorization"},
+		"allow_credentials": true,
+	}}
+	if _, err := Load(sampleCfg, l); !errors.Is(err, ErrInsecureConfig) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "ERROR: [CORS] error [credentials-wildcard-origin]") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	buf.Reset()
+	sampleCfg[Namespace].(map[string]interface{})["allow_insecure"] = true
+	if _, err := Load(sampleCfg, l); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "WARNING: [CORS] error [credentials-wildcard-origin]") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
@@ -0,0 +1,173 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// LivePolicy holds the Policy applied by the middlewares behind an atomic pointer, so it can be
+// replaced at runtime (e.g. from the admin handler) without restarting the service. The requests
+// in flight finish with the policy they started with.
+type LivePolicy struct {
+	current atomic.Pointer[Policy]
+	l       logging.Logger
+	opts    []Option
+	// mu serializes the updates, so none of them is lost
+	mu sync.Mutex
+	// cfg is the configuration of the current policy before applying the defaults
+	cfg   Config
+	admin *adminHandler
+	// derived are the policies built from this one, rebuilt on every update
+	derived []*LivePolicy
+	// derive builds the configuration of a derived policy from the one of its parent
+	derive func(Config) Config
+}
+
+// NewLivePolicy builds the Policy of the Config as NewPolicy does. If the Config enables the admin
+// handler on its own port, the server is started in the background until Close is called.
+func NewLivePolicy(cfg Config, l logging.Logger, opts ...Option) (*LivePolicy, error) {
+	p, err := NewPolicy(cfg, l, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		l = logging.NoOp
+	}
+	lp := &LivePolicy{l: l, opts: opts, cfg: cfg}
+	lp.current.Store(p)
+	if cfg.Admin != nil && cfg.Admin.Token != "" {
+		lp.admin = &adminHandler{live: lp, token: cfg.Admin.Token, path: cfg.Admin.Path}
+		if cfg.Admin.Port != 0 {
+			lp.admin.listen(cfg.Admin.Port)
+		}
+	}
+	return lp, nil
+}
+
+// Close stops the dedicated server of the admin handler, if any, so its port can be reused by the
+// next policy (e.g. after a configuration reload). The policy keeps handling the requests.
+func (lp *LivePolicy) Close() error {
+	if lp.admin == nil {
+		return nil
+	}
+	return lp.admin.close()
+}
+
+// Policy returns the policy currently applied
+func (lp *LivePolicy) Policy() *Policy {
+	return lp.current.Load()
+}
+
+// Config returns the configuration of the current policy, with all the defaults applied
+func (lp *LivePolicy) Config() Config {
+	return lp.Policy().Config()
+}
+
+// SetDebug enables or disables the debug messages of the current policy
+func (lp *LivePolicy) SetDebug(enabled bool) {
+	lp.Policy().SetDebug(enabled)
+}
+
+// Update builds a new policy from the configuration returned by fn, which receives a copy of the
+// current one (before applying the defaults), and replaces the current policy with it. The current
+// policy is kept if the new one can not be built.
+func (lp *LivePolicy) Update(fn func(Config) (Config, error)) (Config, error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	cfg, err := fn(lp.cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	p, err := NewPolicy(cfg, lp.l, lp.opts...)
+	if err != nil {
+		return Config{}, err
+	}
+	derived := make([]*Policy, len(lp.derived))
+	for i, d := range lp.derived {
+		if derived[i], err = NewPolicy(d.derive(cfg), d.l, d.opts...); err != nil {
+			return Config{}, fmt.Errorf("derived policy: %w", err)
+		}
+	}
+
+	p.SetDebug(lp.Policy().debugEnabled())
+	lp.current.Store(p)
+	lp.cfg = cfg
+	for i, d := range lp.derived {
+		d.replace(derived[i], d.derive(cfg))
+	}
+	return p.Config(), nil
+}
+
+// Derive returns a live policy built from the configuration returned by fn, which receives a copy of
+// the current one (before applying the defaults). Every update of this policy rebuilds the derived
+// ones with fn, so they follow its changes. The derived policies never serve the admin handler.
+func (lp *LivePolicy) Derive(fn func(Config) Config) (*LivePolicy, error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	cfg := fn(lp.cfg)
+	cfg.Admin = nil
+	d, err := NewLivePolicy(cfg, lp.l, lp.opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.derive = func(parent Config) Config {
+		cfg := fn(parent)
+		cfg.Admin = nil
+		return cfg
+	}
+	lp.derived = append(lp.derived, d)
+	return d, nil
+}
+
+// replace stores the policy rebuilt by the parent of a derived policy
+func (lp *LivePolicy) replace(p *Policy, cfg Config) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	p.SetDebug(lp.Policy().debugEnabled())
+	lp.current.Store(p)
+	lp.cfg = cfg
+}
+
+// Handler wraps the next handler with the current policy. It implements the mux.HandlerMiddleware
+// interface. If the admin handler is enabled on a path, the requests to that path are sent to it.
+func (lp *LivePolicy) Handler(next http.Handler) http.Handler {
+	type wrapped struct {
+		p *Policy
+		h http.Handler
+	}
+	var cached atomic.Pointer[wrapped]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lp.ServesAdmin(r) {
+			lp.admin.ServeHTTP(w, r)
+			return
+		}
+		p := lp.current.Load()
+		c := cached.Load()
+		if c == nil || c.p != p {
+			c = &wrapped{p: p, h: p.Handler(next)}
+			cached.Store(c)
+		}
+		c.h.ServeHTTP(w, r)
+	})
+}
+
+// AdminHandler returns the admin handler, or nil if the configuration does not enable it, so it can
+// be mounted on any router
+func (lp *LivePolicy) AdminHandler() http.Handler {
+	if lp.admin == nil {
+		return nil
+	}
+	return lp.admin
+}
+
+// ServesAdmin reports whether the request is for the admin handler mounted on a path
+func (lp *LivePolicy) ServesAdmin(r *http.Request) bool {
+	return lp.admin != nil && lp.admin.path != "" && r.URL.Path == lp.admin.path
+}
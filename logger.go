@@ -0,0 +1,99 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// debugLogger implements the rs/cors logger interface on top of a logging.Logger. The messages are
+// sent synchronously at the debug level, so the level of the injected logger decides if they are
+// printed. The headers are rendered as name="value" fields instead of a formatted map.
+type debugLogger struct {
+	l       logging.Logger
+	enabled atomic.Bool
+}
+
+func newDebugLogger(l logging.Logger, enabled bool) *debugLogger {
+	d := &debugLogger{l: l}
+	d.enabled.Store(enabled)
+	return d
+}
+
+// Printf implements the rs/cors Logger interface
+func (d *debugLogger) Printf(format string, v ...interface{}) {
+	if !d.enabled.Load() {
+		return
+	}
+
+	var fields []string
+	args := make([]interface{}, len(v))
+	for i, a := range v {
+		if h, ok := a.(http.Header); ok {
+			fields = append(fields, headerFields(h)...)
+			continue
+		}
+		args[i] = a
+	}
+	if len(fields) == 0 {
+		d.l.Debug(logPrefix, strings.TrimSpace(fmt.Sprintf(format, args...)))
+		return
+	}
+	for i, a := range args {
+		if a == nil {
+			args[i] = ""
+		}
+	}
+	msg := strings.TrimSuffix(strings.TrimSpace(fmt.Sprintf(format, args...)), ":")
+	d.l.Debug(logPrefix, msg, strings.Join(fields, " "))
+}
+
+// decision logs the outcome of the evaluation of a cross-origin request as a list of fields
+func (d *debugLogger) decision(dec decision) {
+	if !d.enabled.Load() || dec.origin == "" {
+		return
+	}
+
+	result := "allowed"
+	if !dec.allowed {
+		result = "rejected"
+	}
+	fields := []string{
+		"decision=" + result,
+		"preflight=" + strconv.FormatBool(dec.preflight),
+		"origin=" + strconv.Quote(dec.origin),
+		"method=" + strconv.Quote(dec.method),
+	}
+	if dec.headers != "" {
+		fields = append(fields, "headers="+strconv.Quote(dec.headers))
+	}
+	if dec.rule != "" {
+		fields = append(fields, "rule="+strconv.Quote(dec.rule))
+	}
+	if dec.reason != ReasonNone {
+		fields = append(fields, "reason="+string(dec.reason))
+	}
+	if dec.detail != "" {
+		fields = append(fields, "detail="+strconv.Quote(dec.detail))
+	}
+	d.l.Debug(logPrefix, strings.Join(fields, " "))
+}
+
+func headerFields(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = name + "=" + strconv.Quote(strings.Join(h[name], ", "))
+	}
+	return fields
+}
@@ -0,0 +1,73 @@
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+func TestPolicy_debugLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+
+	goroutines := runtime.NumGoroutine()
+	p, err := NewPolicy(Config{AllowOrigins: []string{"https://a.example.com"}, Debug: true}, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n := runtime.NumGoroutine(); n != goroutines {
+		t.Errorf("the policy should not start any goroutine: %d != %d", n, goroutines)
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	serve := func() {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", "https://b.example.com")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve()
+	re := regexp.MustCompile(`(\d\d\d\d\/\d\d\/\d\d \d\d:\d\d:\d\d\s+)`)
+	expected := `DEBUG: [CORS] decision=rejected preflight=false origin="https://b.example.com" method="GET" reason=origin
+DEBUG: [CORS] Handler: Actual request
+DEBUG: [CORS] Actual request no headers added: origin 'https://b.example.com' not allowed
+`
+	if msg := re.ReplaceAllString(buf.String(), ""); msg != expected {
+		t.Errorf("unexpected log:\n%s", msg)
+	}
+
+	buf.Reset()
+	p.SetDebug(false)
+	serve()
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	p.SetDebug(true)
+	serve()
+	if buf.Len() == 0 {
+		t.Error("the debug messages should be logged again")
+	}
+}
+
+func TestPolicy_debugLoggerLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("INFO", buf, "")
+	p, err := NewPolicy(Config{Debug: true}, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+	req.Header.Add("Origin", "https://b.example.com")
+	p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	if buf.Len() != 0 {
+		t.Errorf("the debug messages should honour the level of the logger: %s", buf.String())
+	}
+}
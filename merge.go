@@ -0,0 +1,38 @@
+package cors
+
+import "github.com/luraproject/lura/v3/config"
+
+// MergeExtraConfig returns an extra config holding the CORS namespace of the override merged over the
+// base one, key by key, so the override only has to declare the options it changes. It returns nil if
+// none of them defines the namespace. When one of the namespaces is not an object, the override wins,
+// so the error can be reported by the parser.
+func MergeExtraConfig(base, override config.ExtraConfig) config.ExtraConfig {
+	b, hasBase := base[Namespace]
+	o, hasOverride := override[Namespace]
+	switch {
+	case !hasBase && !hasOverride:
+		return nil
+	case !hasOverride:
+		return config.ExtraConfig{Namespace: b}
+	case !hasBase:
+		return config.ExtraConfig{Namespace: o}
+	}
+
+	bm, ok := b.(map[string]interface{})
+	if !ok {
+		return config.ExtraConfig{Namespace: o}
+	}
+	om, ok := o.(map[string]interface{})
+	if !ok {
+		return config.ExtraConfig{Namespace: o}
+	}
+
+	merged := make(map[string]interface{}, len(bm)+len(om))
+	for k, v := range bm {
+		merged[k] = v
+	}
+	for k, v := range om {
+		merged[k] = v
+	}
+	return config.ExtraConfig{Namespace: merged}
+}
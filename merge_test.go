@@ -0,0 +1,40 @@
+package cors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeExtraConfig(t *testing.T) {
+	base := map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins": []interface{}{"*"},
+		"max_age":       "1h",
+	}}
+	override := map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins":     []interface{}{"https://example.com"},
+		"allow_credentials": true,
+	}}
+
+	merged := MergeExtraConfig(base, override)
+	expected := map[string]interface{}{
+		"allow_origins":     []interface{}{"https://example.com"},
+		"allow_credentials": true,
+		"max_age":           "1h",
+	}
+	if !reflect.DeepEqual(merged[Namespace], expected) {
+		t.Errorf("unexpected merged config: %v", merged)
+	}
+	if len(base[Namespace].(map[string]interface{})) != 2 {
+		t.Error("the base config should not be modified")
+	}
+
+	if v := MergeExtraConfig(base, nil); !reflect.DeepEqual(v[Namespace], base[Namespace]) {
+		t.Errorf("unexpected merged config: %v", v)
+	}
+	if v := MergeExtraConfig(nil, override); !reflect.DeepEqual(v[Namespace], override[Namespace]) {
+		t.Errorf("unexpected merged config: %v", v)
+	}
+	if v := MergeExtraConfig(nil, nil); v != nil {
+		t.Errorf("unexpected merged config: %v", v)
+	}
+}
@@ -0,0 +1,126 @@
+package cors
+
+import "sync"
+
+// DefaultMetricsMaxOrigins is the default number of distinct origins reported to the metrics collector
+const DefaultMetricsMaxOrigins = 100
+
+// OtherOrigin replaces the origins reported to the metrics collector once the limit of distinct
+// origins is reached
+const OtherOrigin = "other"
+
+// Measurement describes a cross-origin request handled by the CORS policy
+type Measurement struct {
+	Preflight bool
+	Allowed   bool
+	Reason    Reason
+	// Origin is the origin of the request, or OtherOrigin if too many distinct origins were seen
+	Origin string
+}
+
+// Labels returns the measurement as a set of labels, ready to be used by a metrics backend
+func (m Measurement) Labels() map[string]string {
+	labels := map[string]string{
+		"type":     "actual",
+		"decision": "allowed",
+		"reason":   string(m.Reason),
+		"origin":   m.Origin,
+	}
+	if m.Preflight {
+		labels["type"] = "preflight"
+	}
+	if !m.Allowed {
+		labels["decision"] = "rejected"
+	}
+	return labels
+}
+
+// MetricsCollector receives a measurement for every cross-origin request handled by the CORS
+// policy. Implementations must be safe for concurrent use.
+type MetricsCollector interface {
+	Collect(Measurement)
+}
+
+// WithMetrics sends a measurement of every cross-origin request to the collector. In order to
+// protect the metrics backend from an unbounded number of series, only the first maxOrigins
+// distinct origins are reported (DefaultMetricsMaxOrigins if maxOrigins is not positive) and
+// the rest are grouped as OtherOrigin. The limit is shared by all the policies built with the
+// same option.
+func WithMetrics(c MetricsCollector, maxOrigins int) Option {
+	if maxOrigins <= 0 {
+		maxOrigins = DefaultMetricsMaxOrigins
+	}
+	m := &metrics{collector: c, limit: maxOrigins, origins: map[string]struct{}{}}
+	return func(p *Policy) {
+		p.metrics = m
+	}
+}
+
+type metrics struct {
+	collector MetricsCollector
+	limit     int
+	mu        sync.RWMutex
+	origins   map[string]struct{}
+}
+
+func (m *metrics) collect(d decision) {
+	if d.origin == "" {
+		return
+	}
+	m.collector.Collect(Measurement{
+		Preflight: d.preflight,
+		Allowed:   d.allowed,
+		Reason:    d.reason,
+		Origin:    m.label(d.origin),
+	})
+}
+
+func (m *metrics) label(origin string) string {
+	m.mu.RLock()
+	_, ok := m.origins[origin]
+	full := len(m.origins) >= m.limit
+	m.mu.RUnlock()
+	if ok {
+		return origin
+	}
+	if full {
+		return OtherOrigin
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.origins) >= m.limit {
+		return OtherOrigin
+	}
+	m.origins[origin] = struct{}{}
+	return origin
+}
+
+// MemoryCollector is a MetricsCollector keeping the counters in memory
+type MemoryCollector struct {
+	mu       sync.Mutex
+	counters map[Measurement]int
+}
+
+// NewMemoryCollector returns an empty MemoryCollector
+func NewMemoryCollector() *MemoryCollector {
+	return &MemoryCollector{counters: map[Measurement]int{}}
+}
+
+// Collect implements the MetricsCollector interface
+func (c *MemoryCollector) Collect(m Measurement) {
+	c.mu.Lock()
+	c.counters[m]++
+	c.mu.Unlock()
+}
+
+// Snapshot returns a copy of the counters
+func (c *MemoryCollector) Snapshot() map[Measurement]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[Measurement]int, len(c.counters))
+	for k, v := range c.counters {
+		out[k] = v
+	}
+	return out
+}
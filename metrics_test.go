@@ -0,0 +1,70 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithMetrics(t *testing.T) {
+	collector := NewMemoryCollector()
+	p, err := NewPolicy(Config{
+		AllowOrigins: []string{"https://a.example.com", "https://b.example.com"},
+		AllowMethods: []string{"GET", "PUT"},
+		AllowHeaders: []string{"X-Test", "Content-Type"},
+	}, nil, WithMetrics(collector, 2))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, headers := range []map[string]string{
+		{},
+		{"Origin": "https://a.example.com"},
+		{"Origin": "https://a.example.com"},
+		{"Origin": "https://c.example.com"},
+		{"Origin": "https://d.example.com"},
+		{"Origin": "https://b.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type,x-test"},
+		{"Origin": "https://b.example.com", "Access-Control-Request-Method": "DELETE"},
+		{"Origin": "https://b.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "x-other"},
+		{"Origin": "https://b.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Private-Network": "true"},
+	} {
+		method := "GET"
+		if headers["Access-Control-Request-Method"] != "" {
+			method = "OPTIONS"
+		}
+		req, _ := http.NewRequest(method, "https://example.com/foo", http.NoBody)
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expected := map[Measurement]int{
+		{Allowed: true, Origin: "https://a.example.com"}:                     2,
+		{Reason: ReasonOrigin, Origin: "https://c.example.com"}:              1,
+		{Reason: ReasonOrigin, Origin: OtherOrigin}:                          1,
+		{Preflight: true, Allowed: true, Origin: OtherOrigin}:                1,
+		{Preflight: true, Reason: ReasonMethod, Origin: OtherOrigin}:         1,
+		{Preflight: true, Reason: ReasonHeader, Origin: OtherOrigin}:         1,
+		{Preflight: true, Reason: ReasonPrivateNetwork, Origin: OtherOrigin}: 1,
+	}
+	if snapshot := collector.Snapshot(); !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("unexpected measurements: %v", snapshot)
+	}
+}
+
+func TestMeasurement_Labels(t *testing.T) {
+	labels := Measurement{Preflight: true, Reason: ReasonHeader, Origin: "https://a.example.com"}.Labels()
+	expected := map[string]string{
+		"type":     "preflight",
+		"decision": "rejected",
+		"reason":   "header",
+		"origin":   "https://a.example.com",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+}
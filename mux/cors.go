@@ -1,15 +1,10 @@
 package mux
 
 import (
-	"bufio"
-	"io"
-	"log"
-
 	krakendcors "github.com/krakend/krakend-cors/v3"
 	"github.com/luraproject/lura/v3/config"
 	"github.com/luraproject/lura/v3/logging"
 	"github.com/luraproject/lura/v3/router/mux"
-	"github.com/rs/cors"
 )
 
 // New returns a mux.HandlerMiddleware (which implements the http.Handler interface)
@@ -18,51 +13,25 @@ func New(e config.ExtraConfig) mux.HandlerMiddleware {
 	return NewWithLogger(e, nil)
 }
 
-func NewWithLogger(e config.ExtraConfig, l logging.Logger) mux.HandlerMiddleware {
-	tmp := krakendcors.ConfigGetter(e)
-	if tmp == nil {
-		return nil
-	}
-	cfg, ok := tmp.(krakendcors.Config)
-	if !ok {
+// NewWithLogger returns a mux.HandlerMiddleware with the CORS configuration defined in the ExtraConfig,
+// reporting the configuration problems and the debug messages through the injected logger. It returns
+// nil if the ExtraConfig has no valid CORS configuration. The options customize the policy (e.g. to
+// collect metrics). Use Close to stop the dedicated server of its admin handler, if any.
+func NewWithLogger(e config.ExtraConfig, l logging.Logger, opts ...krakendcors.Option) mux.HandlerMiddleware {
+	cfg, err := krakendcors.Load(e, l)
+	if err != nil {
 		return nil
 	}
-
-	if len(cfg.AllowOrigins) == 0 {
-		cfg.AllowOrigins = []string{"*"}
-	}
-	if len(cfg.AllowHeaders) == 0 {
-		cfg.AllowHeaders = []string{"*"}
-	}
-
-	c := cors.New(cors.Options{
-		AllowedOrigins:       cfg.AllowOrigins,
-		AllowedMethods:       cfg.AllowMethods,
-		AllowedHeaders:       cfg.AllowHeaders,
-		ExposedHeaders:       cfg.ExposeHeaders,
-		AllowCredentials:     cfg.AllowCredentials,
-		AllowPrivateNetwork:  cfg.AllowPrivateNetwork,
-		OptionsPassthrough:   cfg.OptionsPassthrough,
-		OptionsSuccessStatus: cfg.OptionsSuccessStatus,
-		Debug:                cfg.Debug,
-		MaxAge:               int(cfg.MaxAge.Seconds()),
-	})
-	if l == nil || !cfg.Debug {
-		return c
-	}
-
-	r, w := io.Pipe()
-	c.Log = log.New(w, "", log.LstdFlags)
-	go writeLog(r, l)
-
-	return c
+	return newWithConfig(cfg, l, opts...)
 }
 
-func writeLog(r *io.PipeReader, l logging.Logger) {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
-
-	for scanner.Scan() {
-		l.Debug("[CORS]", scanner.Text())
+func newWithConfig(cfg krakendcors.Config, l logging.Logger, opts ...krakendcors.Option) mux.HandlerMiddleware {
+	p, err := krakendcors.NewLivePolicy(cfg, l, opts...)
+	if err != nil {
+		if l != nil {
+			l.Error("[CORS]", err.Error())
+		}
+		return nil
 	}
+	return p
 }
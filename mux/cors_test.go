@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	krakendcors "github.com/krakend/krakend-cors/v3"
 	"github.com/luraproject/lura/v3/logging"
 )
 
@@ -234,3 +237,285 @@ func assertHeaders(t *testing.T, resHeaders http.Header, expHeaders map[string]s
 var testHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte("bar"))
 })
+
+func TestNewWithLogger_strict(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, _ := logging.NewLogger("DEBUG", buf, "")
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"strict": true,
+			"allowed_origins": [ "http://foobar.com" ]
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	if h := NewWithLogger(sampleCfg, logger); h != nil {
+		t.Error("The corsMw should be nil.\n")
+	}
+	msg := "ERROR: [CORS] Strict validation failed: 1 invalid value(s) in security/cors: " +
+		`security/cors.allowed_origins: unknown key, did you mean "allow_origins"?`
+	if !strings.Contains(buf.String(), msg) {
+		t.Error("unexpected logged msg:", buf.String())
+	}
+}
+
+func TestNewWithLogger_insecure(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, _ := logging.NewLogger("DEBUG", buf, "")
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_credentials": true
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	if h := NewWithLogger(sampleCfg, logger); h != nil {
+		t.Error("The corsMw should be nil.\n")
+	}
+	if !strings.Contains(buf.String(), "ERROR: [CORS] error [credentials-wildcard-origin]") {
+		t.Error("unexpected logged msg:", buf.String())
+	}
+}
+
+func TestAllowOriginsRegex(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "http://foobar.com" ],
+			"allow_origins_regex": [ "https://pr-[0-9]+\\.preview\\.example\\.com" ]
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	h := New(sampleCfg)
+	handler := h.Handler(testHandler)
+
+	for origin, allowed := range map[string]string{
+		"http://foobar.com":                     "http://foobar.com",
+		"https://pr-1234.preview.example.com":   "https://pr-1234.preview.example.com",
+		"https://pr-1234.preview.example.com.x": "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		handler.ServeHTTP(res, req)
+
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                        "Origin",
+			"Access-Control-Allow-Origin": allowed,
+		})
+	}
+}
+
+func TestAllowOriginsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.json")
+	if err := os.WriteFile(path, []byte(`["http://foobar.com"]`), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	sampleCfg := map[string]interface{}{
+		"security/cors": map[string]interface{}{
+			"allow_origins_file": path,
+		},
+	}
+	h := New(sampleCfg)
+	handler := h.Handler(testHandler)
+
+	for origin, allowed := range map[string]string{
+		"http://foobar.com":  "http://foobar.com",
+		"http://example.com": "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		handler.ServeHTTP(res, req)
+
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                        "Origin",
+			"Access-Control-Allow-Origin": allowed,
+		})
+	}
+
+	sampleCfg["security/cors"].(map[string]interface{})["allow_origins_file"] = path + ".missing"
+	if New(sampleCfg) != nil {
+		t.Error("The corsMw should be nil.\n")
+	}
+}
+
+func TestNewWithLogger_metrics(t *testing.T) {
+	collector := krakendcors.NewMemoryCollector()
+	sampleCfg := map[string]interface{}{"security/cors": map[string]interface{}{
+		"allow_origins": []interface{}{"http://foobar.com"},
+	}}
+	h := NewWithLogger(sampleCfg, nil, krakendcors.WithMetrics(collector, 0))
+	handler := h.Handler(testHandler)
+
+	for _, origin := range []string{"http://foobar.com", "http://example.com"} {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	snapshot := collector.Snapshot()
+	if snapshot[krakendcors.Measurement{Allowed: true, Origin: "http://foobar.com"}] != 1 ||
+		snapshot[krakendcors.Measurement{Reason: krakendcors.ReasonOrigin, Origin: "http://example.com"}] != 1 {
+		t.Errorf("unexpected measurements: %v", snapshot)
+	}
+}
+
+func TestAllowPrivateNetworkOrigins(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+				"allow_private_network_origins": [ "http://intranet.example.com" ],
+				"private_network_access_name": "office-printer",
+				"private_network_access_id": "01:23:45:67:89:0A"
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	h := New(sampleCfg)
+	if h == nil {
+		t.Error("the middleware should be built")
+		return
+	}
+	handler := h.Handler(testHandler)
+
+	for origin, expected := range map[string]map[string]string{
+		"http://intranet.example.com": {
+			"Vary":                                 "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+			"Access-Control-Allow-Origin":          "*",
+			"Access-Control-Allow-Methods":         "GET",
+			"Access-Control-Allow-Private-Network": "true",
+			"Private-Network-Access-Name":          "office-printer",
+			"Private-Network-Access-Id":            "01:23:45:67:89:0A",
+		},
+		"http://foobar.com": {
+			"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET",
+		},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Access-Control-Request-Private-Network", "true")
+		req.Header.Add("Origin", origin)
+		handler.ServeHTTP(res, req)
+		if res.Code != http.StatusNoContent {
+			t.Errorf("Invalid status code: %d should be 204", res.Code)
+		}
+		for _, k := range []string{"Access-Control-Allow-Private-Network", "Private-Network-Access-Name", "Private-Network-Access-Id"} {
+			if v := res.Header().Get(k); v != expected[k] {
+				t.Errorf("unexpected %s header for %s: %q", k, origin, v)
+			}
+			delete(expected, k)
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestNullOrigin(t *testing.T) {
+	for cfg, allowed := range map[string]string{
+		`{ "security/cors": { "allow_origins": [ "*" ] } }`:                            "",
+		`{ "security/cors": { "allow_origins": [ "*" ], "allow_null_origin": true } }`: "*",
+	} {
+		sampleCfg := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(cfg), &sampleCfg); err != nil {
+			t.Error(err)
+			return
+		}
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Origin", "null")
+		New(sampleCfg).Handler(testHandler).ServeHTTP(res, req)
+
+		expected := map[string]string{
+			"Vary":                        "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			"Access-Control-Allow-Origin": allowed,
+		}
+		if allowed != "" {
+			expected["Access-Control-Allow-Methods"] = "GET"
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestOriginValidator(t *testing.T) {
+	krakendcors.RegisterOriginValidator("mux-test", func(_ krakendcors.Config, _ logging.Logger) (krakendcors.OriginValidator, error) {
+		return krakendcors.OriginValidatorFunc(func(r *http.Request, origin string) (bool, string) {
+			return origin == "https://"+r.Host, "same host"
+		}), nil
+	})
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": { "origin_validator": "mux-test" } }`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	handler := NewWithLogger(sampleCfg, nil).Handler(testHandler)
+
+	for origin, allowed := range map[string]string{
+		"https://example.com": "https://example.com",
+		"https://foobar.com":  "",
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		handler.ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                        "Origin",
+			"Access-Control-Allow-Origin": allowed,
+		})
+	}
+}
+
+func TestTenants(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+				"allow_origins": [ "https://www.example.com" ],
+				"tenant_fallback": "reject",
+				"tenants": {
+					"api.customer-a.com": { "allow_origins": [ "https://app.customer-a.com" ] },
+					"api.customer-b.com": { "allow_origins": [ "https://app.customer-b.com" ] }
+				}
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	handler := NewWithLogger(sampleCfg, nil).Handler(testHandler)
+
+	for _, tc := range []struct {
+		host    string
+		origin  string
+		allowed string
+	}{
+		{"api.customer-a.com", "https://app.customer-a.com", "https://app.customer-a.com"},
+		{"api.customer-a.com", "https://app.customer-b.com", ""},
+		{"api.customer-b.com", "https://app.customer-b.com", "https://app.customer-b.com"},
+		{"example.com", "https://www.example.com", ""},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://"+tc.host+"/foo", http.NoBody)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Origin", tc.origin)
+		handler.ServeHTTP(res, req)
+		expected := map[string]string{
+			"Vary":                        "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			"Access-Control-Allow-Origin": tc.allowed,
+		}
+		if tc.allowed != "" {
+			expected["Access-Control-Allow-Methods"] = "GET"
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
@@ -0,0 +1,332 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	krakendcors "github.com/krakend/krakend-cors/v3"
+	"github.com/luraproject/lura/v3/config"
+	"github.com/luraproject/lura/v3/logging"
+	"github.com/luraproject/lura/v3/router/mux"
+)
+
+// NewService returns a mux.HandlerMiddleware applying the CORS configuration of the service and the
+// ones defined in the extra config of its endpoints.
+func NewService(cfg config.ServiceConfig) mux.HandlerMiddleware {
+	return NewServiceWithLogger(cfg, nil)
+}
+
+// NewServiceWithLogger returns a mux.HandlerMiddleware applying the CORS configuration of the service
+// and the ones defined in the extra config of its endpoints, merged over the service one. Requests are
+// routed to the policy of the endpoint matching their path and method (the requested one for the
+// preflights) and, if no endpoint matches, to the service policy. Endpoints with an invalid policy get
+// no CORS headers at all. When the allowed methods are set to "auto", every endpoint allows the methods
+// of all the endpoints sharing its path. When the allowed headers are set to "auto", every endpoint
+// allows the headers it forwards to its backends (the input_headers) plus the always allowed ones. It
+// returns nil if neither the service nor the endpoints define a CORS configuration. The options are
+// applied to all the policies. Use Close to stop the dedicated server of the admin handler, if any.
+func NewServiceWithLogger(cfg config.ServiceConfig, l logging.Logger, opts ...krakendcors.Option) mux.HandlerMiddleware {
+	if l == nil {
+		l = logging.NoOp
+	}
+
+	var service mux.HandlerMiddleware
+	serviceCfg, err := krakendcors.Load(cfg.ExtraConfig, l)
+	if err == nil {
+		service = newWithConfig(serviceCfg, l, opts...)
+	}
+
+	methods := map[string][]string{}
+	for _, e := range cfg.Endpoints {
+		key := newPathPattern(e.Endpoint).String()
+		methods[key] = append(methods[key], endpointMethod(e))
+	}
+
+	var routes []route
+	customized := false
+	for _, e := range cfg.Endpoints {
+		pattern := newPathPattern(e.Endpoint)
+		r := route{method: endpointMethod(e), pattern: pattern, mw: service}
+
+		_, hasOwnPolicy := e.ExtraConfig[krakendcors.Namespace]
+		if !hasOwnPolicy && (service == nil || !(serviceCfg.AutoMethods || serviceCfg.AutoHeaders)) {
+			routes = append(routes, r)
+			continue
+		}
+		customized = true
+
+		endpointCfg := serviceCfg
+		if hasOwnPolicy {
+			endpointCfg, err = krakendcors.Load(krakendcors.MergeExtraConfig(cfg.ExtraConfig, e.ExtraConfig), l)
+			if err != nil {
+				l.Error("[CORS] Disabling CORS for the endpoint", r.method, e.Endpoint)
+				r.mw = nil
+				routes = append(routes, r)
+				continue
+			}
+			l.Debug("[CORS] Custom policy for the endpoint", r.method, e.Endpoint)
+		}
+		derive := endpointPolicy(endpointCfg, e, methods[pattern.String()])
+		r.mw = derivedPolicy(service, derive, serviceCfg, l, opts...)
+		routes = append(routes, r)
+	}
+
+	if !customized {
+		return service
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].pattern.moreSpecific(routes[j].pattern)
+	})
+	return &serviceMiddleware{service: service, routes: routes}
+}
+
+// endpointPolicy returns the function building the policy of the endpoint from the service one, so the
+// changes made by the admin handler to the service origins, methods and headers reach the endpoints
+// not declaring them
+func endpointPolicy(endpointCfg krakendcors.Config, e *config.EndpointConfig, methods []string) func(krakendcors.Config) krakendcors.Config {
+	own, _ := e.ExtraConfig[krakendcors.Namespace].(map[string]interface{})
+	return func(service krakendcors.Config) krakendcors.Config {
+		cfg := endpointCfg
+		if _, ok := own["allow_origins"]; !ok {
+			cfg.AllowOrigins = service.AllowOrigins
+		}
+		if _, ok := own["allow_methods"]; !ok {
+			cfg.AllowMethods, cfg.AutoMethods = service.AllowMethods, service.AutoMethods
+		}
+		if _, ok := own["allow_headers"]; !ok {
+			cfg.AllowHeaders, cfg.AutoHeaders = service.AllowHeaders, service.AutoHeaders
+		}
+		// only the service policy is managed by the admin handler
+		cfg.Admin = nil
+		if cfg.AutoMethods {
+			cfg.AllowMethods = methods
+		}
+		if cfg.AutoHeaders {
+			cfg.AllowHeaders = e.HeadersToPass
+		}
+		return cfg
+	}
+}
+
+// derivedPolicy builds the policy of an endpoint. When the service policy is a live one, the endpoint
+// policy is derived from it, so it is rebuilt on every update of the service one.
+func derivedPolicy(service mux.HandlerMiddleware, derive func(krakendcors.Config) krakendcors.Config,
+	serviceCfg krakendcors.Config, l logging.Logger, opts ...krakendcors.Option) mux.HandlerMiddleware {
+	live, ok := service.(*krakendcors.LivePolicy)
+	if !ok {
+		return newWithConfig(derive(serviceCfg), l, opts...)
+	}
+	p, err := live.Derive(derive)
+	if err != nil {
+		l.Error("[CORS]", err.Error())
+		return nil
+	}
+	return p
+}
+
+type route struct {
+	method  string
+	pattern pathPattern
+	mw      mux.HandlerMiddleware
+}
+
+type serviceMiddleware struct {
+	service mux.HandlerMiddleware
+	routes  []route
+}
+
+// Handler implements the mux.HandlerMiddleware interface
+func (s *serviceMiddleware) Handler(next http.Handler) http.Handler {
+	handlers := make([]http.Handler, len(s.routes))
+	for i, r := range s.routes {
+		handlers[i] = wrap(r.mw, next)
+	}
+	fallback := wrap(s.service, next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i := s.route(r); i >= 0 {
+			handlers[i].ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// Explain explains the decision of the policy applied to the request. The endpoints with an invalid
+// policy get no CORS headers, so all their cross-origin requests are rejected.
+func (s *serviceMiddleware) Explain(r *http.Request) krakendcors.Decision {
+	mw := s.service
+	if i := s.route(r); i >= 0 {
+		mw = s.routes[i].mw
+	}
+	return explain(mw, r)
+}
+
+// route returns the index of the route of the request or -1 if it must be handled by the service
+// policy: the admin requests and the ones not matching any endpoint path
+func (s *serviceMiddleware) route(r *http.Request) int {
+	if live, ok := s.service.(*krakendcors.LivePolicy); ok && live.ServesAdmin(r) {
+		return -1
+	}
+
+	method := r.Method
+	if r.Method == http.MethodOptions {
+		if m := r.Header.Get("Access-Control-Request-Method"); m != "" {
+			method = m
+		}
+	}
+
+	pathMatch := -1
+	for i, rt := range s.routes {
+		if !rt.pattern.match(r.URL.Path) {
+			continue
+		}
+		if rt.method == method {
+			return i
+		}
+		if pathMatch < 0 {
+			pathMatch = i
+		}
+	}
+	return pathMatch
+}
+
+// Close stops the dedicated server of the admin handler of the service policy, if any
+func (s *serviceMiddleware) Close() error {
+	return Close(s.service)
+}
+
+// Close stops the dedicated server of the admin handler of the CORS middleware returned by the
+// constructors of this package, if any, so its port can be reused
+func Close(mw mux.HandlerMiddleware) error {
+	if c, ok := mw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Explain explains the decision of the CORS middleware returned by the constructors of this package
+func Explain(mw mux.HandlerMiddleware, r *http.Request) krakendcors.Decision {
+	return explain(mw, r)
+}
+
+func explain(mw mux.HandlerMiddleware, r *http.Request) krakendcors.Decision {
+	if e, ok := mw.(interface {
+		Explain(*http.Request) krakendcors.Decision
+	}); ok {
+		return e.Explain(r)
+	}
+
+	d := krakendcors.Decision{
+		Origin:  r.Header.Get("Origin"),
+		Method:  r.Method,
+		Allowed: true,
+		Headers: http.Header{},
+	}
+	if m := r.Header.Get("Access-Control-Request-Method"); r.Method == http.MethodOptions && m != "" {
+		d.Preflight = true
+		d.Method = m
+	}
+	if d.Origin != "" {
+		d.Allowed = false
+		d.Reason = krakendcors.ReasonOrigin
+		d.Steps = []krakendcors.Step{{Check: krakendcors.StepOrigin, Value: d.Origin, Rule: "no valid CORS policy"}}
+	}
+	return d
+}
+
+func wrap(mw mux.HandlerMiddleware, next http.Handler) http.Handler {
+	if mw == nil {
+		return next
+	}
+	return mw.Handler(next)
+}
+
+func endpointMethod(e *config.EndpointConfig) string {
+	if e.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(e.Method)
+}
+
+const (
+	segmentWildcard = iota
+	segmentParam
+	segmentStatic
+)
+
+type segment struct {
+	kind  int
+	value string
+}
+
+// pathPattern matches the request paths against the endpoint definitions, supporting both the
+// {param} and :param placeholders and the trailing catch-all (*) segments
+type pathPattern []segment
+
+func newPathPattern(endpoint string) pathPattern {
+	parts := strings.Split(strings.Trim(endpoint, "/"), "/")
+	p := make(pathPattern, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "*"):
+			p = append(p, segment{kind: segmentWildcard})
+			return p
+		case strings.HasPrefix(part, ":"), strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			p = append(p, segment{kind: segmentParam})
+		default:
+			p = append(p, segment{kind: segmentStatic, value: part})
+		}
+	}
+	return p
+}
+
+func (p pathPattern) match(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range p {
+		if s.kind == segmentWildcard {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if s.kind == segmentStatic && s.value != parts[i] {
+			return false
+		}
+		if s.kind == segmentParam && parts[i] == "" {
+			return false
+		}
+	}
+	return len(parts) == len(p)
+}
+
+// String returns the pattern with all the placeholders written the same way, so the patterns of
+// the same routes can be compared
+func (p pathPattern) String() string {
+	parts := make([]string, len(p))
+	for i, s := range p {
+		switch s.kind {
+		case segmentWildcard:
+			parts[i] = "*"
+		case segmentParam:
+			parts[i] = ":"
+		default:
+			parts[i] = s.value
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// moreSpecific reports whether p should be checked before o: static segments win over
+// placeholders and placeholders over catch-alls, as routers do
+func (p pathPattern) moreSpecific(o pathPattern) bool {
+	for i := 0; i < len(p) && i < len(o); i++ {
+		if p[i].kind != o[i].kind {
+			return p[i].kind > o[i].kind
+		}
+	}
+	return len(p) > len(o)
+}
@@ -0,0 +1,369 @@
+package mux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v3/config"
+)
+
+func TestNewService(t *testing.T) {
+	serviceCfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{ "security/cors": {
+			"allow_origins": [ "*" ],
+			"allow_methods": [ "GET", "POST", "DELETE" ]
+			}
+		}`), &serviceCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	adminCfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{ "security/cors": {
+			"allow_origins": [ "https://admin.example.com" ],
+			"allow_headers": [ "Authorization" ],
+			"allow_credentials": true
+			}
+		}`), &adminCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	invalidCfg := map[string]interface{}{"security/cors": map[string]interface{}{"allow_credentials": true}}
+
+	h := NewService(config.ServiceConfig{
+		ExtraConfig: serviceCfg,
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/public/{id}", Method: "GET"},
+			{Endpoint: "/admin/:id", Method: "GET", ExtraConfig: adminCfg},
+			{Endpoint: "/admin/:id", Method: "DELETE"},
+			{Endpoint: "/admin/audit", Method: "POST", ExtraConfig: invalidCfg},
+		},
+	})
+	if h == nil {
+		t.Error("The corsMw should not be nil.\n")
+		return
+	}
+	handler := h.Handler(testHandler)
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		origin   string
+		method   string
+		expected map[string]string
+	}{
+		{
+			name:   "service policy",
+			path:   "/public/42",
+			origin: "http://foobar.com",
+			method: "GET",
+			expected: map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			name:   "endpoint policy",
+			path:   "/admin/42",
+			origin: "https://admin.example.com",
+			method: "GET",
+			expected: map[string]string{
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "https://admin.example.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:   "endpoint policy with a disallowed origin",
+			path:   "/admin/42",
+			origin: "http://foobar.com",
+			method: "GET",
+			expected: map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			name:   "service policy for another method of the same path",
+			path:   "/admin/42",
+			origin: "http://foobar.com",
+			method: "DELETE",
+			expected: map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "DELETE",
+			},
+		},
+		{
+			name:     "invalid endpoint policy",
+			path:     "/admin/audit",
+			origin:   "http://foobar.com",
+			method:   "POST",
+			expected: map[string]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("OPTIONS", "https://example.com"+tc.path, http.NoBody)
+			req.Header.Add("Origin", tc.origin)
+			req.Header.Add("Access-Control-Request-Method", tc.method)
+			handler.ServeHTTP(res, req)
+			assertHeaders(t, res.Header(), tc.expected)
+		})
+	}
+}
+
+func TestNewService_noEndpointConfig(t *testing.T) {
+	if NewService(config.ServiceConfig{Endpoints: []*config.EndpointConfig{{Endpoint: "/foo"}}}) != nil {
+		t.Error("The corsMw should be nil.\n")
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/foo", "/foo", true},
+		{"/foo", "/foo/", true},
+		{"/foo", "/bar", false},
+		{"/foo/{id}", "/foo/42", true},
+		{"/foo/:id", "/foo/42", true},
+		{"/foo/:id", "/foo", false},
+		{"/foo/:id", "/foo/42/bar", false},
+		{"/foo/:id/bar", "/foo/42/bar", true},
+		{"/foo/*", "/foo/42/bar", true},
+		{"/foo/*path", "/foo", true},
+		{"/", "/", true},
+	} {
+		if newPathPattern(tc.pattern).match(tc.path) != tc.match {
+			t.Errorf("unexpected result matching %s with %s", tc.path, tc.pattern)
+		}
+	}
+}
+
+func TestNewService_autoMethods(t *testing.T) {
+	h := NewService(config.ServiceConfig{
+		ExtraConfig: map[string]interface{}{"security/cors": map[string]interface{}{"allow_methods": "auto"}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users/{id}", Method: "GET"},
+			{Endpoint: "/users/:id", Method: "PUT"},
+			{Endpoint: "/users/{id}", Method: "DELETE"},
+			{Endpoint: "/users", Method: "POST"},
+		},
+	})
+	if h == nil {
+		t.Error("The corsMw should not be nil.\n")
+		return
+	}
+	handler := h.Handler(testHandler)
+
+	for _, tc := range []struct {
+		path    string
+		method  string
+		allowed bool
+	}{
+		{"/users/42", "GET", true},
+		{"/users/42", "PUT", true},
+		{"/users/42", "DELETE", true},
+		{"/users/42", "POST", false},
+		{"/users", "POST", true},
+		{"/users", "DELETE", false},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com"+tc.path, http.NoBody)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		handler.ServeHTTP(res, req)
+
+		expected := map[string]string{
+			"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		}
+		if tc.allowed {
+			expected["Access-Control-Allow-Origin"] = "*"
+			expected["Access-Control-Allow-Methods"] = tc.method
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestNewService_autoHeaders(t *testing.T) {
+	h := NewService(config.ServiceConfig{
+		ExtraConfig: map[string]interface{}{"security/cors": map[string]interface{}{
+			"allow_headers":        "auto",
+			"always_allow_headers": []interface{}{"Content-Type"},
+		}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", Method: "GET", HeadersToPass: []string{"Authorization"}},
+			{Endpoint: "/users", Method: "POST", HeadersToPass: []string{"X-Tenant"}},
+			{Endpoint: "/open", Method: "GET", HeadersToPass: []string{"*"}},
+		},
+	})
+	if h == nil {
+		t.Error("The corsMw should not be nil.\n")
+		return
+	}
+	handler := h.Handler(testHandler)
+
+	for _, tc := range []struct {
+		path    string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"/users", "GET", "authorization,content-type", true},
+		{"/users", "GET", "x-tenant", false},
+		{"/users", "POST", "content-type,x-tenant", true},
+		{"/users", "POST", "authorization", false},
+		{"/open", "GET", "x-anything", true},
+		{"/unknown", "GET", "content-type", true},
+		{"/unknown", "GET", "authorization", false},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "https://example.com"+tc.path, http.NoBody)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		handler.ServeHTTP(res, req)
+
+		expected := map[string]string{
+			"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		}
+		if tc.allowed {
+			expected["Access-Control-Allow-Origin"] = "*"
+			expected["Access-Control-Allow-Methods"] = tc.method
+			expected["Access-Control-Allow-Headers"] = tc.headers
+		}
+		assertHeaders(t, res.Header(), expected)
+	}
+}
+
+func TestNewService_autoHeadersEmpty(t *testing.T) {
+	mw := NewService(config.ServiceConfig{
+		ExtraConfig: map[string]interface{}{"security/cors": map[string]interface{}{
+			"allow_headers": "auto",
+			"enforce":       true,
+		}},
+		Endpoints: []*config.EndpointConfig{{Endpoint: "/users", Method: "GET"}},
+	})
+	handler := mw.Handler(testHandler)
+
+	for _, tc := range []struct {
+		headers string
+		allowed bool
+	}{
+		{"content-type", true},
+		{"accept,x-requested-with", true},
+		{"authorization", false},
+	} {
+		req, _ := http.NewRequest("OPTIONS", "https://example.com/users", http.NoBody)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if allowed := res.Header().Get("Access-Control-Allow-Headers") == tc.headers; allowed != tc.allowed {
+			t.Errorf("%s: unexpected response: %d %v", tc.headers, res.Code, res.Header())
+		}
+		if d := Explain(mw, req); d.Allowed != tc.allowed {
+			t.Errorf("%s: the decision does not match the response: %+v", tc.headers, d)
+		}
+	}
+}
+
+func TestNewService_admin(t *testing.T) {
+	serviceCfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{ "security/cors": {
+			"allow_origins": [ "https://www.example.com" ],
+			"allow_methods": "auto",
+			"admin": { "path": "/__cors", "token": "secret" }
+			}
+		}`), &serviceCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	h := NewService(config.ServiceConfig{
+		ExtraConfig: serviceCfg,
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", Method: "GET"},
+			{Endpoint: "/*", Method: "GET"},
+		},
+	})
+	handler := h.Handler(testHandler)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "https://example.com/__cors", http.NoBody)
+	req.Header.Add("Authorization", "Bearer secret")
+	handler.ServeHTTP(res, req)
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("the admin handler should answer the request: %d %s", res.Code, res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "https://example.com/users", http.NoBody)
+	req.Header.Add("Authorization", "Bearer secret")
+	handler.ServeHTTP(res, req)
+	if res.Header().Get("Content-Type") == "application/json; charset=utf-8" {
+		t.Error("the endpoints should not serve the admin handler")
+	}
+}
+
+func TestNewService_adminUpdatesEndpoints(t *testing.T) {
+	serviceCfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{ "security/cors": {
+			"allow_origins": [ "https://www.example.com" ],
+			"allow_methods": "auto",
+			"admin": { "path": "/__cors", "token": "secret" }
+			}
+		}`), &serviceCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	h := NewService(config.ServiceConfig{
+		ExtraConfig: serviceCfg,
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", Method: "GET"},
+			{Endpoint: "/orders", Method: "GET", ExtraConfig: config.ExtraConfig{
+				"security/cors": map[string]interface{}{"allow_credentials": true},
+			}},
+			{Endpoint: "/internal", Method: "GET", ExtraConfig: config.ExtraConfig{
+				"security/cors": map[string]interface{}{"allow_origins": []interface{}{"https://www.example.com"}},
+			}},
+		},
+	})
+	handler := h.Handler(testHandler)
+
+	allowed := func(path string) string {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "https://example.com"+path, http.NoBody)
+		req.Header.Add("Origin", "https://partner.example.org")
+		handler.ServeHTTP(res, req)
+		return res.Header().Get("Access-Control-Allow-Origin")
+	}
+	if allowed("/users") != "" {
+		t.Error("the partner origin should not be allowed before the update")
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "https://example.com/__cors", strings.NewReader(`{"add":{"allow_origins":["https://partner.example.org"]}}`))
+	req.Header.Add("Authorization", "Bearer secret")
+	handler.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d %s", res.Code, res.Body.String())
+		return
+	}
+
+	for _, path := range []string{"/users", "/orders"} {
+		if got := allowed(path); got != "https://partner.example.org" {
+			t.Errorf("%s: the added origin should be allowed, got %q", path, got)
+		}
+	}
+	if got := allowed("/internal"); got != "" {
+		t.Errorf("the endpoint declaring its origins should not follow the service ones, got %q", got)
+	}
+}
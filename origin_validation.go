@@ -0,0 +1,134 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// nullOrigin is the origin sent by the browsers for the opaque origins: sandboxed iframes, local
+// files, some redirects...
+const nullOrigin = "null"
+
+// idnaProfile converts the internationalized hosts to ASCII. Unlike the lookup profile of the idna
+// package, it accepts the underscores, common in the names of the internal services.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// defaultPorts are removed from the normalized origins
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// NormalizeOrigin returns the canonical form of a serialized origin, so equivalent origins can be
+// compared as strings: the scheme and the host are lowercased, the internationalized hosts are
+// converted to their punycode form and the default port of the scheme is removed. It returns an
+// error if the value is not a scheme and a host with an optional port, like origins containing a
+// path (even a trailing slash), a query, a fragment or userinfo.
+func NormalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	switch {
+	case err != nil:
+		return "", errors.Unwrap(err)
+	case u.Scheme == "" || u.Opaque != "" || u.Host == "":
+		return "", errors.New("a scheme and a host are required")
+	case u.User != nil:
+		return "", errors.New("userinfo is not allowed")
+	case u.Path != "" || u.RawPath != "":
+		return "", errors.New("a path (or a trailing slash) is not allowed")
+	case u.RawQuery != "" || u.ForceQuery:
+		return "", errors.New("a query is not allowed")
+	case u.Fragment != "" || strings.Contains(origin, "#"):
+		return "", errors.New("a fragment is not allowed")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return "", errors.New("a scheme and a host are required")
+	}
+	if port == "" && strings.HasSuffix(u.Host, ":") {
+		return "", errors.New("empty port")
+	}
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n == 0 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + strings.ToLower(host) + "]"
+	} else if host, err = idnaProfile.ToASCII(host); err != nil {
+		return "", err
+	}
+
+	if port == "" || defaultPorts[scheme] == port {
+		return scheme + "://" + host, nil
+	}
+	return scheme + "://" + host + ":" + port, nil
+}
+
+// normalizeOriginEntry normalizes an entry of a list of allowed origins. The wildcard, the null
+// origin and the entries with wildcards are only lowercased.
+func normalizeOriginEntry(origin string) (string, error) {
+	if origin == "*" || origin == nullOrigin || strings.Contains(origin, "*") {
+		return strings.ToLower(origin), nil
+	}
+	return NormalizeOrigin(origin)
+}
+
+// validOrigin reports whether the value is a serialized origin: a scheme and a host with an optional
+// port, without userinfo, path, query nor fragment
+func validOrigin(origin string) bool {
+	_, err := NormalizeOrigin(origin)
+	return err == nil
+}
+
+// nullOriginAllowed reports whether the null origin is accepted, either with the allow_null_origin
+// option or by listing it in the allowed origins. The wildcards do not allow it.
+func (c Config) nullOriginAllowed() bool {
+	return c.AllowNullOrigin || contains(c.AllowOrigins, nullOrigin)
+}
+
+// checkOrigin rejects the null origin, unless it is allowed, and the malformed origins before they
+// are compared with the allowed ones
+func (p *Policy) checkOrigin(origin string) Reason {
+	if origin == nullOrigin {
+		if p.cfg.nullOriginAllowed() {
+			return ReasonNone
+		}
+		return ReasonOrigin
+	}
+	if !validOrigin(origin) {
+		return ReasonMalformedOrigin
+	}
+	return ReasonNone
+}
+
+// filterOrigin hides the origins rejected by checkOrigin from rs/cors, which would compare them as
+// plain strings (and allow them with a wildcard). Without the Origin header, the request gets the
+// same response as a rejected one. The malformed origins are logged. The returned copy is only for
+// rs/cors: the next handler gets the original request.
+func (p *Policy) filterOrigin(r *http.Request) *http.Request {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r
+	}
+	reason := p.checkOrigin(origin)
+	if reason == ReasonNone {
+		return r
+	}
+	if reason == ReasonMalformedOrigin && p.logger != nil {
+		p.logger.Warning(logPrefix, "Rejecting a request with a malformed origin:", strconv.Quote(origin))
+	}
+	r = r.Clone(r.Context())
+	r.Header.Del("Origin")
+	return r
+}
@@ -0,0 +1,225 @@
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+func TestValidOrigin(t *testing.T) {
+	for origin, valid := range map[string]bool{
+		"https://example.com":       true,
+		"http://localhost:8080":     true,
+		"https://[::1]:8443":        true,
+		"chrome-extension://abcdef": true,
+		"null":                      false,
+		"example.com":               false,
+		"https://":                  false,
+		"https://example.com/":      false,
+		"https://example.com/path":  false,
+		"https://user@example.com":  false,
+		"https://example.com?a=b":   false,
+		"https://example.com#x":     false,
+		"https://example.com:":      false,
+		"https://example.com:99999": false,
+		"javascript:alert(1)":       false,
+		"https://exa mple.com":      false,
+	} {
+		if validOrigin(origin) != valid {
+			t.Errorf("unexpected validation of %q: %v", origin, !valid)
+		}
+	}
+}
+
+func TestPolicy_nullAndMalformedOrigins(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     Config
+		origin  string
+		allowed string
+	}{
+		{name: "null with wildcard", cfg: Config{}, origin: "null"},
+		{name: "null allowed with wildcard", cfg: Config{AllowNullOrigin: true}, origin: "null", allowed: "*"},
+		{
+			name:    "null allowed with a list",
+			cfg:     Config{AllowOrigins: []string{"https://example.com"}, AllowNullOrigin: true},
+			origin:  "null",
+			allowed: "null",
+		},
+		{
+			name:    "null listed",
+			cfg:     Config{AllowOrigins: []string{"https://example.com", "null"}},
+			origin:  "null",
+			allowed: "null",
+		},
+		{
+			name:   "null with regex",
+			cfg:    Config{AllowOriginsRegex: []string{".*"}},
+			origin: "null",
+		},
+		{name: "malformed", cfg: Config{}, origin: "https://example.com/path"},
+		{name: "valid", cfg: Config{}, origin: "https://example.com", allowed: "*"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			l, _ := logging.NewLogger("WARNING", buf, "")
+			p, err := NewPolicy(tc.cfg, l)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+			req.Header.Add("Origin", tc.origin)
+			if d := p.evaluate(req); d.allowed != (tc.allowed != "") {
+				t.Errorf("unexpected decision: %+v", d)
+			}
+
+			res := httptest.NewRecorder()
+			var forwarded *http.Request
+			p.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { forwarded = r })).ServeHTTP(res, req)
+			if forwarded != req {
+				t.Errorf("the next handler should get the original request: %v", forwarded)
+			}
+			if v := res.Header().Get("Access-Control-Allow-Origin"); v != tc.allowed {
+				t.Errorf("unexpected allowed origin: %q", v)
+			}
+			if res.Header().Get("Vary") != "Origin" {
+				t.Errorf("unexpected vary header: %v", res.Header()["Vary"])
+			}
+			if logged := strings.Contains(buf.String(), "malformed origin"); logged != (tc.name == "malformed") {
+				t.Errorf("unexpected log: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	for origin, expected := range map[string]string{
+		"https://example.com":               "https://example.com",
+		"HTTPS://Example.COM":               "https://example.com",
+		"https://example.com:443":           "https://example.com",
+		"http://example.com:80":             "http://example.com",
+		"http://example.com:443":            "http://example.com:443",
+		"wss://example.com:443":             "wss://example.com",
+		"https://bücher.example":            "https://xn--bcher-kva.example",
+		"https://xn--bcher-kva.example":     "https://xn--bcher-kva.example",
+		"http://my_service:8080":            "http://my_service:8080",
+		"http://[::1]:80":                   "http://[::1]",
+		"http://[2001:DB8::1]:8080":         "http://[2001:db8::1]:8080",
+		"chrome-extension://abcdefghijklmn": "chrome-extension://abcdefghijklmn",
+	} {
+		n, err := NormalizeOrigin(origin)
+		if err != nil {
+			t.Errorf("unexpected error normalizing %q: %v", origin, err)
+			continue
+		}
+		if n != expected {
+			t.Errorf("unexpected normalization of %q: %q", origin, n)
+		}
+	}
+
+	for origin, msg := range map[string]string{
+		"https://example.com/":     "a path (or a trailing slash) is not allowed",
+		"https://user@example.com": "userinfo is not allowed",
+		"example.com":              "a scheme and a host are required",
+		"https://example.com?":     "a query is not allowed",
+	} {
+		if _, err := NormalizeOrigin(origin); err == nil || err.Error() != msg {
+			t.Errorf("unexpected error normalizing %q: %v", origin, err)
+		}
+	}
+}
+
+func TestParseConfig_normalizedOrigins(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins": []interface{}{"https://Example.com:443", "https://bücher.example", "https://example.org/", "*"},
+	}})
+	want := "1 invalid value(s) in security/cors: " +
+		"security/cors.allow_origins[2]: invalid origin: a path (or a trailing slash) is not allowed"
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if strings.Join(cfg.AllowOrigins, " ") != "https://example.com https://xn--bcher-kva.example *" {
+		t.Errorf("unexpected origins: %v", cfg.AllowOrigins)
+	}
+}
+
+func TestLoad_invalidOrigins(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  map[string]interface{}
+		path string
+	}{
+		{
+			name: "trailing slash",
+			cfg:  map[string]interface{}{"allow_origins": []interface{}{"https://app.example.com/"}},
+			path: "security/cors.allow_origins[0]",
+		},
+		{
+			name: "not a list",
+			cfg:  map[string]interface{}{"allow_origins": "https://app.example.com"},
+			path: "security/cors.allow_origins",
+		},
+		{
+			name: "pattern",
+			cfg:  map[string]interface{}{"allow_origins_patterns": []interface{}{"https://*.com"}},
+			path: "security/cors.allow_origins_patterns[0]",
+		},
+		{
+			name: "private network origins",
+			cfg: map[string]interface{}{
+				"allow_origins":                 []interface{}{"https://app.example.com"},
+				"allow_private_network":         true,
+				"allow_private_network_origins": []interface{}{"app.example.com"},
+			},
+			path: "security/cors.allow_private_network_origins[0]",
+		},
+		{
+			name: "tenant",
+			cfg: map[string]interface{}{"tenants": map[string]interface{}{
+				"api.example.com": map[string]interface{}{"allow_origins": []interface{}{"https://app.example.com/"}},
+			}},
+			path: "security/cors.tenants.api.example.com.allow_origins[0]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			l, _ := logging.NewLogger("DEBUG", buf, "")
+			_, err := Load(map[string]interface{}{Namespace: tc.cfg}, l)
+			if err == nil || !strings.Contains(err.Error(), tc.path+": ") {
+				t.Errorf("an invalid origin should be fatal out of the strict mode: %v", err)
+			}
+			if !strings.Contains(buf.String(), "ERROR: [CORS] Invalid allowed origins:") {
+				t.Errorf("unexpected log: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestPolicy_normalizedOrigins(t *testing.T) {
+	p, err := NewPolicy(Config{AllowOrigins: []string{"https://Example.com", "https://bücher.example"}}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for origin, allowed := range map[string]bool{
+		"https://example.com":           true,
+		"https://EXAMPLE.com:443":       true,
+		"https://xn--bcher-kva.example": true,
+		"https://example.com:8443":      false,
+		"http://example.com":            false,
+	} {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if v := res.Header().Get("Access-Control-Allow-Origin"); (v == origin) != allowed {
+			t.Errorf("unexpected allowed origin for %q: %q", origin, v)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// originKeys lists the options restricting the allowed origins. Without their valid values, the
+// policy would fall back to allowing every origin.
+var originKeys = []string{"allow_origins", "allow_origins_regex", "allow_origins_patterns", "allow_private_network_origins"}
+
+// restrictOrigins reports whether any of the errors belongs to an option restricting the allowed
+// origins
+func (e ValidationErrors) restrictOrigins() bool {
+	for _, fe := range e {
+		if fe.restrictsOrigins() {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictsOrigins reports whether the error belongs to an option restricting the allowed origins, at
+// the top level or in a tenant
+func (e *FieldError) restrictsOrigins() bool {
+	for _, k := range originKeys {
+		if e.Key == k || strings.HasSuffix(e.Key, "."+k) {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginMatcher checks the origins of the requests against the allowed origins of a Config,
+// including the ones defined as regular expressions and the ones listed in the origins file
+type OriginMatcher struct {
+	all       bool
+	origins   []string
+	wildcards []wildcard
+	regexps   []*regexp.Regexp
+	patterns  *patternMatcher
+	file      *OriginsFile
+}
+
+// NewOriginMatcher compiles the allowed origins of the Config. The regular expressions must match
+// the whole origin, so they are anchored at both ends. It returns nil when the Config has no regular
+// expressions, patterns nor origins file.
+func NewOriginMatcher(cfg Config, l logging.Logger) (*OriginMatcher, error) {
+	if len(cfg.AllowOriginsRegex) == 0 && len(cfg.AllowOriginsPatterns) == 0 && cfg.AllowOriginsFile == "" {
+		return nil, nil
+	}
+
+	m := newStaticMatcher(cfg.AllowOrigins)
+	for _, expr := range cfg.AllowOriginsRegex {
+		re, err := compileOriginRegex(expr)
+		if err != nil {
+			return nil, err
+		}
+		m.regexps = append(m.regexps, re)
+	}
+	if len(cfg.AllowOriginsPatterns) > 0 {
+		patterns := make([]*originPattern, 0, len(cfg.AllowOriginsPatterns))
+		for _, pattern := range cfg.AllowOriginsPatterns {
+			p, err := compileOriginPattern(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid origin pattern %q: %w", pattern, err)
+			}
+			patterns = append(patterns, p)
+		}
+		m.patterns = newPatternMatcher(patterns)
+	}
+	if cfg.AllowOriginsFile != "" {
+		var err error
+		m.file, err = NewOriginsFile(cfg.AllowOriginsFile, cfg.AllowOriginsFileInterval, l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// newStaticMatcher builds a matcher for a list of origins, normalized as NormalizeOrigin does. The
+// entries that can not be normalized are just lowercased.
+func newStaticMatcher(origins []string) *OriginMatcher {
+	m := &OriginMatcher{}
+	for _, o := range origins {
+		if n, err := normalizeOriginEntry(o); err == nil {
+			o = n
+		} else {
+			o = strings.ToLower(o)
+		}
+		if o == "*" {
+			m.all = true
+			continue
+		}
+		if i := strings.IndexByte(o, '*'); i >= 0 {
+			m.wildcards = append(m.wildcards, wildcard{o[:i], o[i+1:]})
+			continue
+		}
+		m.origins = append(m.origins, o)
+	}
+	return m
+}
+
+// Match reports whether the origin is allowed
+func (m *OriginMatcher) Match(origin string) bool {
+	_, ok := m.MatchRule(origin)
+	return ok
+}
+
+// MatchRule reports whether the origin is allowed and, if so, the rule allowing it: the matching
+// entry of the allowed origins, the matching pattern or regular expression or the path of the origins
+// file.
+// The origin is normalized before comparing it with the entries, and the regular expressions are
+// tried with both the received and the normalized forms.
+func (m *OriginMatcher) MatchRule(origin string) (string, bool) {
+	if m.all {
+		return "*", true
+	}
+	normalized, err := NormalizeOrigin(origin)
+	if err != nil {
+		normalized = strings.ToLower(origin)
+	}
+	for _, o := range m.origins {
+		if o == normalized {
+			return o, true
+		}
+	}
+	for _, w := range m.wildcards {
+		if w.match(normalized) {
+			return w.prefix + "*" + w.suffix, true
+		}
+	}
+	if m.patterns != nil {
+		if rule, ok := m.patterns.match(normalized); ok {
+			return rule, true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(origin) || re.MatchString(normalized) {
+			return re.String(), true
+		}
+	}
+	if m.file != nil && m.file.Match(origin) {
+		return m.file.path, true
+	}
+	return "", false
+}
+
+func compileOriginRegex(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// wildcard matches the origins containing a single '*', as rs/cors does
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+func (w wildcard) match(s string) bool {
+	return len(s) >= len(w.prefix)+len(w.suffix) &&
+		strings.HasPrefix(s, w.prefix) &&
+		strings.HasSuffix(s, w.suffix)
+}
@@ -0,0 +1,152 @@
+package cors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// DefaultOriginsFileInterval is the minimum time between two checks for changes in the origins file
+const DefaultOriginsFileInterval = 10 * time.Second
+
+// OriginsFile keeps the set of origins listed in a file, reloading it when the file changes.
+// There is no background goroutine watching the file: the first request arriving after the
+// check interval looks for changes and, if any, reloads the file while the rest of the requests
+// keep using the previous set of origins. The set is swapped atomically and a file that can not
+// be parsed does not replace it.
+type OriginsFile struct {
+	path      string
+	interval  time.Duration
+	l         logging.Logger
+	nextCheck atomic.Int64
+	snapshot  atomic.Pointer[originsSnapshot]
+	mu        sync.Mutex
+}
+
+type originsSnapshot struct {
+	matcher *OriginMatcher
+	modTime time.Time
+	size    int64
+}
+
+// NewOriginsFile loads the origins listed in the file at path, returning an error if it can not be
+// read or parsed. The file can contain a JSON list of strings or an origin per line, ignoring the
+// empty lines and the ones starting with '#'. The origins are normalized as NormalizeOrigin does and
+// the file is rejected if any of them is invalid. The wildcards are rejected too, so a file can never allow
+// every origin (e.g. to a policy allowing credentials).
+func NewOriginsFile(path string, interval time.Duration, l logging.Logger) (*OriginsFile, error) {
+	if l == nil {
+		l = logging.NoOp
+	}
+	if interval <= 0 {
+		interval = DefaultOriginsFileInterval
+	}
+	f := &OriginsFile{path: path, interval: interval, l: l}
+	s, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	f.snapshot.Store(s)
+	f.nextCheck.Store(time.Now().Add(interval).UnixNano())
+	return f, nil
+}
+
+// Match reports whether the origin is listed in the file
+func (f *OriginsFile) Match(origin string) bool {
+	f.refresh()
+	return f.snapshot.Load().matcher.Match(origin)
+}
+
+// Origins returns the origins currently loaded
+func (f *OriginsFile) Origins() []string {
+	return append([]string{}, f.snapshot.Load().matcher.origins...)
+}
+
+func (f *OriginsFile) refresh() {
+	now := time.Now()
+	next := f.nextCheck.Load()
+	if now.UnixNano() < next || !f.nextCheck.CompareAndSwap(next, now.Add(f.interval).UnixNano()) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.l.Error(logPrefix, "Unable to check the origins file:", err.Error())
+		return
+	}
+	current := f.snapshot.Load()
+	if info.ModTime().Equal(current.modTime) && info.Size() == current.size {
+		return
+	}
+
+	s, err := f.load()
+	if err != nil {
+		f.l.Error(logPrefix, "Keeping the previous origins:", err.Error())
+		return
+	}
+	f.snapshot.Store(s)
+	f.l.Info(logPrefix, fmt.Sprintf("Reloaded %d origins from %s", len(s.matcher.origins), f.path))
+}
+
+func (f *OriginsFile) load() (*originsSnapshot, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the origins file: %w", err)
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the origins file: %w", err)
+	}
+	origins, err := parseOriginsFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the origins file %s: %w", f.path, err)
+	}
+	return &originsSnapshot{matcher: newStaticMatcher(origins), modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func parseOriginsFile(b []byte) ([]string, error) {
+	var origins []string
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		if err := json.Unmarshal(b, &origins); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			origins = append(origins, line)
+		}
+	}
+
+	var errs []error
+	for i, o := range origins {
+		if strings.Contains(o, "*") {
+			errs = append(errs, fmt.Errorf("invalid origin %q: wildcards are not allowed (use allow_origins_patterns)", o))
+			continue
+		}
+		n, err := normalizeOriginEntry(o)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid origin %q: %w", o, err))
+			continue
+		}
+		origins[i] = n
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return origins, nil
+}
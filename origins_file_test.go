@@ -0,0 +1,166 @@
+package cors
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+func TestOriginsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.txt")
+	if err := os.WriteFile(path, []byte("# partners\nhttps://a.example.com\n\nhttps://b.example.com\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+	f, err := NewOriginsFile(path, time.Millisecond, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assertOrigins := func(expected map[string]bool) {
+		t.Helper()
+		for origin, allowed := range expected {
+			if f.Match(origin) != allowed {
+				t.Errorf("unexpected result for %s: %v", origin, !allowed)
+			}
+		}
+	}
+
+	assertOrigins(map[string]bool{
+		"https://a.example.com":     true,
+		"https://b.example.com":     true,
+		"https://cdn.b.example.com": false,
+		"https://c.example.com":     false,
+	})
+
+	update := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Error(err)
+		}
+		future := time.Now().Add(time.Minute)
+		if err := os.Chtimes(path, future, future); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	update(`["https://c.example.com"]`)
+	assertOrigins(map[string]bool{
+		"https://a.example.com": false,
+		"https://c.example.com": true,
+	})
+	if !strings.Contains(buf.String(), "INFO: [CORS] Reloaded 1 origins from "+path) {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	update(`["https://d.example.com"`)
+	assertOrigins(map[string]bool{
+		"https://c.example.com": true,
+		"https://d.example.com": false,
+	})
+	if !strings.Contains(buf.String(), "ERROR: [CORS] Keeping the previous origins: parsing the origins file") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestNewOriginsFile_missing(t *testing.T) {
+	if _, err := NewOriginsFile(filepath.Join(t.TempDir(), "unknown.txt"), 0, nil); err == nil {
+		t.Error("an error was expected")
+	}
+}
+
+func TestOriginsFile_wildcards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.txt")
+	for _, content := range []string{"*\n", `["https://a.example.com", "https://*.example.com"]`} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := NewOriginsFile(path, 0, nil); err == nil || !strings.Contains(err.Error(), "wildcards are not allowed") {
+			t.Errorf("%s: unexpected error: %v", content, err)
+		}
+	}
+
+	_, err := NewPolicy(Config{AllowCredentials: true, AllowOriginsFile: path}, nil)
+	if err == nil {
+		t.Error("a policy allowing credentials to the origins of a file with wildcards should not be built")
+	}
+
+	if err := os.WriteFile(path, []byte("https://a.example.com\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+	f, err := NewOriginsFile(path, time.Millisecond, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := os.WriteFile(path, []byte("*\n"), 0o600); err != nil {
+		t.Error(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if f.Match("https://evil.com") || !f.Match("https://a.example.com") {
+		t.Errorf("the previous origins should be kept: %v", f.Origins())
+	}
+	if !strings.Contains(buf.String(), "ERROR: [CORS] Keeping the previous origins: parsing the origins file") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestOriginsFile_invalidOrigins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.txt")
+	if err := os.WriteFile(path, []byte("HTTPS://Münich.example.com:443\nhttps://a.example.com\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+	f, err := NewOriginsFile(path, time.Millisecond, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := strings.Join(f.Origins(), " "); got != "https://xn--mnich-kva.example.com https://a.example.com" {
+		t.Errorf("unexpected origins: %s", got)
+	}
+	if !f.Match("https://xn--mnich-kva.example.com") {
+		t.Error("the internationalized origin should be allowed")
+	}
+
+	if err := os.WriteFile(path, []byte("https://bad.example.com/\nhttps://c.example.com\n"), 0o600); err != nil {
+		t.Error(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if f.Match("https://c.example.com") || !f.Match("https://a.example.com") {
+		t.Errorf("the previous origins should be kept: %v", f.Origins())
+	}
+	if !strings.Contains(buf.String(), `invalid origin "https://bad.example.com/": a path (or a trailing slash) is not allowed`) {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	if _, err := NewOriginsFile(path, 0, nil); err == nil {
+		t.Error("a file with invalid origins should not be loaded")
+	}
+}
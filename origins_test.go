@@ -0,0 +1,60 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	m, err := NewOriginMatcher(Config{
+		AllowOrigins:      []string{"https://Example.com", "https://*.static.example.com"},
+		AllowOriginsRegex: []string{`https://pr-\d+\.preview\.example\.com`},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for origin, expected := range map[string]bool{
+		"https://example.com":                        true,
+		"https://EXAMPLE.com":                        true,
+		"https://cdn.static.example.com":             true,
+		"https://pr-1234.preview.example.com":        true,
+		"https://pr-.preview.example.com":            false,
+		"https://pr-1234.preview.example.com.evil.x": false,
+		"http://pr-1234.preview.example.com":         false,
+		"https://example.org":                        false,
+	} {
+		if m.Match(origin) != expected {
+			t.Errorf("unexpected result for %s: %v", origin, !expected)
+		}
+	}
+}
+
+func TestParseConfig_invalidRegex(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins_regex": []interface{}{`https://[a-z]+\.example\.com`, `https://(foo.example.com`},
+	}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if errs[0].Path != "security/cors.allow_origins_regex[1]" || errs[0].Expected != "regular expression" {
+		t.Errorf("unexpected error: %+v", errs[0])
+	}
+	if len(cfg.AllowOriginsRegex) != 1 {
+		t.Errorf("the valid expressions should be reported by ParseConfig: %v", cfg.AllowOriginsRegex)
+	}
+
+	for _, regexps := range [][]interface{}{
+		{`https://(foo.example.com`},
+		{`https://[a-z]+\.example\.com`, `https://(foo.example.com`},
+	} {
+		_, err := Load(map[string]interface{}{Namespace: map[string]interface{}{"allow_origins_regex": regexps}}, nil)
+		if !errors.As(err, &errs) || errs[0].Path != "security/cors.allow_origins_regex["+fmt.Sprint(len(regexps)-1)+"]" {
+			t.Errorf("an invalid expression should be fatal out of the strict mode: %v", err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package cors
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// parser extracts typed values from the CORS namespace, collecting an error for every value
+// with an unexpected type instead of stopping at the first one
+type parser struct {
+	data map[string]interface{}
+	// prefix locates the nested objects (e.g. "audit.")
+	prefix string
+	errs   *ValidationErrors
+}
+
+func newParser(data map[string]interface{}) *parser {
+	return &parser{data: data, errs: &ValidationErrors{}}
+}
+
+// path returns the location of the key under the extra config
+func (p *parser) path(key string) string {
+	return fieldPath(p.prefix + key)
+}
+
+func (p *parser) fail(key, path string, v interface{}, expected string, err error) {
+	*p.errs = append(*p.errs, &FieldError{
+		Key:      p.prefix + key,
+		Path:     path,
+		Got:      jsonType(v),
+		Expected: expected,
+		Err:      err,
+	})
+}
+
+func (p *parser) list(key string) []string {
+	vs, ok := p.data[key]
+	if !ok {
+		return nil
+	}
+	v, ok := vs.([]interface{})
+	if !ok {
+		p.fail(key, p.path(key), vs, "array of strings", nil)
+		return nil
+	}
+	var out []string
+	for i, s := range v {
+		j, ok := s.(string)
+		if !ok {
+			p.fail(key, fmt.Sprintf("%s[%d]", p.path(key), i), s, "string", nil)
+			continue
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
+// listOrAuto parses a list of strings that can be replaced by the "auto" keyword
+func (p *parser) listOrAuto(key string) ([]string, bool) {
+	v, ok := p.data[key]
+	if !ok {
+		return nil, false
+	}
+	if s, ok := v.(string); ok {
+		if s == autoKeyword {
+			return nil, true
+		}
+		p.fail(key, p.path(key), v, `array of strings or "auto"`, fmt.Errorf("unknown keyword %q", s))
+		return nil, false
+	}
+	if _, ok := v.([]interface{}); !ok {
+		p.fail(key, p.path(key), v, `array of strings or "auto"`, nil)
+		return nil, false
+	}
+	return p.list(key), false
+}
+
+const autoKeyword = "auto"
+
+// originList parses a list of allowed origins, normalizing them
+func (p *parser) originList(key string) []string {
+	var out []string
+	for i, o := range p.list(key) {
+		n, err := normalizeOriginEntry(o)
+		if err != nil {
+			p.fail(key, fmt.Sprintf("%s[%d]", p.path(key), i), o, "origin", err)
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func (p *parser) patternList(key string) []string {
+	var out []string
+	for i, pattern := range p.list(key) {
+		if _, err := compileOriginPattern(pattern); err != nil {
+			p.fail(key, fmt.Sprintf("%s[%d]", p.path(key), i), pattern, "origin pattern", err)
+			continue
+		}
+		out = append(out, pattern)
+	}
+	return out
+}
+
+func (p *parser) regexpList(key string) []string {
+	var out []string
+	for i, expr := range p.list(key) {
+		if _, err := compileOriginRegex(expr); err != nil {
+			p.fail(key, fmt.Sprintf("%s[%d]", p.path(key), i), expr, "regular expression", err)
+			continue
+		}
+		out = append(out, expr)
+	}
+	return out
+}
+
+func (p *parser) string(key string) string {
+	v, ok := p.data[key]
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		p.fail(key, p.path(key), v, "string", nil)
+	}
+	return s
+}
+
+func (p *parser) bool(key string) bool {
+	v, ok := p.data[key]
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	if !ok {
+		p.fail(key, p.path(key), v, "boolean", nil)
+	}
+	return b
+}
+
+func (p *parser) int(key string) int {
+	v, ok := p.data[key]
+	if !ok {
+		return 0
+	}
+	switch n := v.(type) {
+	case float64:
+		if n == float64(int(n)) {
+			return int(n)
+		}
+		p.fail(key, p.path(key), v, "integer", fmt.Errorf("%v is not an integer", n))
+	case int:
+		return n
+	case int64:
+		return int(n)
+	default:
+		p.fail(key, p.path(key), v, "integer", nil)
+	}
+	return 0
+}
+
+func (p *parser) duration(key string) time.Duration {
+	v, ok := p.data[key]
+	if !ok {
+		return 0
+	}
+	s, ok := v.(string)
+	if !ok {
+		p.fail(key, p.path(key), v, "duration string", nil)
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		p.fail(key, p.path(key), v, "duration string", err)
+		return 0
+	}
+	return d
+}
+
+// object returns a parser for the nested object under the key, if present
+func (p *parser) object(key string) (*parser, bool) {
+	v, ok := p.data[key]
+	if !ok {
+		return nil, false
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		p.fail(key, p.path(key), v, "object", nil)
+		return nil, false
+	}
+	return &parser{data: m, prefix: p.prefix + key + ".", errs: p.errs}, true
+}
+
+func (p *parser) unknownKeys(known []string) {
+	var unknown []string
+	for k := range p.data {
+		if !contains(known, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, k := range unknown {
+		*p.errs = append(*p.errs, &FieldError{
+			Key:        p.prefix + k,
+			Path:       p.path(k),
+			Got:        jsonType(p.data[k]),
+			Err:        ErrUnknownKey,
+			Suggestion: suggestKey(k, known),
+		})
+	}
+}
+
+// suggestKey returns the known key closest to the received one, or an empty string if none of
+// them is close enough to be considered a typo
+func suggestKey(k string, knownKeys []string) string {
+	best, bestDistance := "", min(len(k)/2, 3)+1
+	for _, known := range knownKeys {
+		if d := levenshtein(k, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
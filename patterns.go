@@ -0,0 +1,153 @@
+package cors
+
+import (
+	"errors"
+	"strings"
+)
+
+// originPattern is an allowed origin with wildcards in the host or in the port. A leading "*." in
+// the host matches one or more labels, so "https://*.example.com" matches "https://a.example.com"
+// and "https://a.b.example.com", but neither "https://example.com" nor "https://example.com.evil.com".
+// A "*" port matches any port, including the default one.
+type originPattern struct {
+	raw        string
+	scheme     string
+	host       string
+	subdomains bool
+	anyPort    bool
+	port       string
+}
+
+// compileOriginPattern parses a pattern. The scheme is required and the wildcards are only accepted
+// as the first label of the host and as the port. The host of the subdomain patterns must have at
+// least two labels (or be localhost) and can not be an IP address.
+func compileOriginPattern(pattern string) (*originPattern, error) {
+	p := &originPattern{raw: pattern}
+	i := strings.Index(pattern, "://")
+	if i <= 0 {
+		return nil, errors.New("a scheme and a host are required")
+	}
+	rest := pattern[i+3:]
+	if strings.HasPrefix(rest, "*.") {
+		p.subdomains = true
+		rest = rest[2:]
+	}
+	if strings.HasSuffix(rest, ":*") {
+		p.anyPort = true
+		rest = strings.TrimSuffix(rest, ":*")
+	}
+	if strings.Contains(rest, "*") {
+		return nil, errors.New(`wildcards are only allowed as "*." at the beginning of the host and as the port`)
+	}
+	origin, err := NormalizeOrigin(pattern[:i+3] + rest)
+	if err != nil {
+		return nil, err
+	}
+	p.scheme, p.host, p.port = splitOrigin(origin)
+	if p.subdomains {
+		if strings.HasPrefix(p.host, "[") || isNumericLabel(p.host[strings.LastIndexByte(p.host, '.')+1:]) {
+			return nil, errors.New("subdomains of an IP address are not allowed")
+		}
+		if !strings.Contains(p.host, ".") && p.host != "localhost" {
+			return nil, errors.New("subdomains of a top level domain are not allowed")
+		}
+	}
+	return p, nil
+}
+
+func (p *originPattern) matchPort(port string) bool {
+	return p.anyPort || p.port == port
+}
+
+// covers reports whether the pattern matches all the origins matched by another, different, one
+func (p *originPattern) covers(o *originPattern) bool {
+	if p.scheme != o.scheme || !(p.anyPort || !o.anyPort && p.port == o.port) {
+		return false
+	}
+	if p.host == o.host && p.subdomains == o.subdomains && p.anyPort == o.anyPort {
+		return false
+	}
+	if p.subdomains {
+		return strings.HasSuffix(o.host, "."+p.host)
+	}
+	return !o.subdomains && p.host == o.host
+}
+
+// splitOrigin splits a normalized origin in its scheme, host and port. The port is empty when it is
+// the default one.
+func splitOrigin(origin string) (scheme, host, port string) {
+	i := strings.Index(origin, "://")
+	if i < 0 {
+		return "", "", ""
+	}
+	scheme, host = origin[:i], origin[i+3:]
+	if j := strings.LastIndexByte(host, ':'); j >= 0 && j > strings.LastIndexByte(host, ']') {
+		host, port = host[:j], host[j+1:]
+	}
+	return scheme, host, port
+}
+
+func isNumericLabel(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// patternMatcher indexes the patterns by scheme and host, so matching an origin takes a lookup per
+// label of its host instead of a comparison per pattern
+type patternMatcher struct {
+	exact      map[string][]*originPattern
+	subdomains map[string][]*originPattern
+}
+
+func newPatternMatcher(patterns []*originPattern) *patternMatcher {
+	m := &patternMatcher{
+		exact:      map[string][]*originPattern{},
+		subdomains: map[string][]*originPattern{},
+	}
+	for _, p := range patterns {
+		key := p.scheme + "://" + p.host
+		if p.subdomains {
+			m.subdomains[key] = append(m.subdomains[key], p)
+		} else {
+			m.exact[key] = append(m.exact[key], p)
+		}
+	}
+	return m
+}
+
+// match checks a normalized origin, returning the matching pattern
+func (m *patternMatcher) match(origin string) (string, bool) {
+	scheme, host, port := splitOrigin(origin)
+	if scheme == "" || host == "" {
+		return "", false
+	}
+	for _, p := range m.exact[scheme+"://"+host] {
+		if p.matchPort(port) {
+			return p.raw, true
+		}
+	}
+	if strings.HasPrefix(host, "[") || strings.HasPrefix(host, ".") || strings.Contains(host, "..") {
+		return "", false
+	}
+	for i := strings.IndexByte(host, '.'); i >= 0; {
+		suffix := host[i+1:]
+		for _, p := range m.subdomains[scheme+"://"+suffix] {
+			if p.matchPort(port) {
+				return p.raw, true
+			}
+		}
+		j := strings.IndexByte(suffix, '.')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return "", false
+}
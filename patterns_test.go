@@ -0,0 +1,142 @@
+package cors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatternMatcher(t *testing.T) {
+	var patterns []*originPattern
+	for _, pattern := range []string{"https://*.example.com", "http://localhost:*", "https://*.bücher.example:8443", "https://api.example.org"} {
+		p, err := compileOriginPattern(pattern)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		patterns = append(patterns, p)
+	}
+	m := newPatternMatcher(patterns)
+
+	for origin, expected := range map[string]string{
+		"https://a.example.com":                   "https://*.example.com",
+		"https://a.b.example.com":                 "https://*.example.com",
+		"https://example.com":                     "",
+		"https://example.com.evil.com":            "",
+		"https://aexample.com":                    "",
+		"http://a.example.com":                    "",
+		"https://a.example.com:8443":              "",
+		"http://localhost":                        "http://localhost:*",
+		"http://localhost:3000":                   "http://localhost:*",
+		"http://a.localhost:3000":                 "",
+		"https://localhost:3000":                  "",
+		"https://shop.xn--bcher-kva.example:8443": "https://*.bücher.example:8443",
+		"https://shop.xn--bcher-kva.example":      "",
+		"https://api.example.org":                 "https://api.example.org",
+		"https://v2.api.example.org":              "",
+		"https://[::1]":                           "",
+		"https://a.example.com.":                  "",
+		"https://xn--bcher-kva.example:8443":      "",
+		"https://evil.com/https://a.example.com":  "",
+		"https://a.example.com@evil.com":          "",
+		"https://evil.com#https://a.example.com":  "",
+		"https://evil.com?https://a.example.com":  "",
+		"https://a.example.comevil.com":           "",
+		"https://a.example.com:443":               "https://*.example.com",
+		"https://a.example.com:443.evil.com":      "",
+		"https://a.example.com%2F.evil.com":       "",
+		"https://a.example.com\\.evil.com":        "",
+		"https://a.example.com%00.evil.com":       "",
+		"https://a.example.com。evil.com":          "",
+		"https://a.example.com。example.com":       "https://*.example.com",
+		"https://xn--e1afmkfd.example.com":        "https://*.example.com",
+		"https://пример.example.com":              "https://*.example.com",
+	} {
+		normalized, err := NormalizeOrigin(origin)
+		if err != nil {
+			if expected != "" {
+				t.Errorf("unexpected error normalizing %q: %v", origin, err)
+			}
+			continue
+		}
+		rule, ok := m.match(normalized)
+		if ok != (expected != "") || rule != expected {
+			t.Errorf("unexpected match of %q: %q %v", origin, rule, ok)
+		}
+	}
+}
+
+func TestCompileOriginPattern(t *testing.T) {
+	for pattern, msg := range map[string]string{
+		"*.example.com":               "a scheme and a host are required",
+		"https://a.*.example.com":     `wildcards are only allowed as "*." at the beginning of the host and as the port`,
+		"https://*example.com":        `wildcards are only allowed as "*." at the beginning of the host and as the port`,
+		"https://*.com":               "subdomains of a top level domain are not allowed",
+		"https://*.192.168.1.1":       "subdomains of an IP address are not allowed",
+		"https://*.example.com/":      "a path (or a trailing slash) is not allowed",
+		"https://*.example.com:99999": `invalid port "99999"`,
+	} {
+		if _, err := compileOriginPattern(pattern); err == nil || err.Error() != msg {
+			t.Errorf("unexpected error compiling %q: %v", pattern, err)
+		}
+	}
+}
+
+func TestPolicy_patterns(t *testing.T) {
+	p, err := NewPolicy(Config{AllowOriginsPatterns: []string{"https://*.example.com", "http://localhost:*"}}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for origin, allowed := range map[string]bool{
+		"https://a.example.com":        true,
+		"http://localhost:8080":        true,
+		"https://example.com.evil.com": false,
+		"https://example.com":          false,
+	} {
+		if ok, _ := p.origins.ValidateOrigin(nil, origin); ok != allowed {
+			t.Errorf("unexpected match of %q", origin)
+		}
+	}
+
+	if _, err := NewPolicy(Config{AllowOriginsPatterns: []string{"https://*.com"}}, nil); err == nil {
+		t.Error("an error was expected")
+	}
+}
+
+func FuzzPatternMatcher(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"example.com", "https://a.example.com"},
+		{"example.com", "https://example.com.evil.com"},
+		{"example.com", "https://aexample.com"},
+		{"example.com", "https://a.example.com:443"},
+		{"bücher.example", "https://a.xn--bcher-kva.example"},
+		{"localhost", "https://a.localhost"},
+		{"example.com", "https://a.example.com。evil.com"},
+		{"a.b.example.com", "https://x.a.b.example.com"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, suffix, origin string) {
+		p, err := compileOriginPattern("https://*." + suffix)
+		if err != nil {
+			return
+		}
+		normalized, err := NormalizeOrigin(origin)
+		if err != nil {
+			return
+		}
+		if _, ok := newPatternMatcher([]*originPattern{p}).match(normalized); !ok {
+			return
+		}
+		scheme, host, port := splitOrigin(normalized)
+		if scheme != "https" || port != "" {
+			t.Errorf("%q matched %q with a different scheme or port", normalized, p.raw)
+		}
+		if !strings.HasSuffix(host, "."+p.host) {
+			t.Errorf("%q matched %q out of its suffix", normalized, p.raw)
+		}
+		if label := strings.TrimSuffix(host, "."+p.host); label == "" || strings.HasPrefix(label, ".") || strings.HasSuffix(label, ".") {
+			t.Errorf("%q matched %q with an empty label", normalized, p.raw)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package cors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v3/logging"
+	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CompatGin restores the legacy defaults of the gin flavour, answering the preflights with a 200
+// status code instead of a 204
+const CompatGin = "gin"
+
+var defaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+
+// defaultAutoHeaders are the headers allowed by rs/cors when its list is empty. They are made explicit
+// for the endpoints without headers to allow, so the decisions report the headers actually allowed.
+var defaultAutoHeaders = []string{"Accept", "Content-Type", "X-Requested-With"}
+
+// Policy is the CORS middleware built from a Config once all the defaults are applied. Both the mux
+// and the gin flavours use it, so the same configuration behaves the same way whatever the router.
+type Policy struct {
+	cfg        Config
+	cors       *cors.Cors
+	origins    OriginValidator
+	headers    map[string]struct{}
+	allHeaders bool
+	metrics    *metrics
+	audit      *auditor
+	log        *debugLogger
+	logger     logging.Logger
+	// privateNetworkOrigins restricts the private network access, if not nil
+	privateNetworkOrigins *OriginMatcher
+	// tenants selects the policy of the request by its host, if not nil
+	tenants *tenantRouter
+	// customOrigins is set when the origins are decided by a validator injected with an Option
+	customOrigins bool
+}
+
+// Option customizes a Policy
+type Option func(*Policy)
+
+// NewPolicy applies the defaults to the Config and builds the CORS handler. The debug messages, if
+// enabled, are sent to the injected logger. Without a logger, rs/cors prints them to the standard
+// output. When the Config declares tenants, a policy is built for every one of them and the handler
+// selects it by the host of the request. The options are applied to all of them.
+func NewPolicy(cfg Config, l logging.Logger, opts ...Option) (*Policy, error) {
+	if len(cfg.Tenants) == 0 {
+		return newPolicy(cfg, l, opts...)
+	}
+
+	base := cfg
+	base.Tenants = nil
+	p, err := newPolicy(base, l, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.tenants = newTenantRouter(cfg.TenantHeader)
+	p.cfg.Tenants = make(map[string]Config, len(cfg.Tenants))
+	for host, tenantCfg := range cfg.Tenants {
+		tp, err := newPolicy(tenantCfg, l, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", host, err)
+		}
+		p.tenants.add(host, tp)
+		p.cfg.Tenants[host] = tp.cfg
+	}
+	if cfg.TenantFallback == TenantFallbackReject {
+		p.tenants.fallback, err = newPolicy(base, l, append(opts, WithOriginValidator(rejectOrigins))...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func newPolicy(cfg Config, l logging.Logger, opts ...Option) (*Policy, error) {
+	if cfg.AllowNullOrigin && !cfg.wildcardOrigin() && !contains(cfg.AllowOrigins, nullOrigin) {
+		cfg.AllowOrigins = append(append([]string{}, cfg.AllowOrigins...), nullOrigin)
+	}
+	var origins OriginValidator
+	if cfg.OriginValidator != "" {
+		v, err := NewOriginValidator(cfg.OriginValidator, cfg, l)
+		if err != nil {
+			return nil, err
+		}
+		origins = v
+	} else {
+		m, err := NewOriginMatcher(cfg, l)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			origins = m
+		}
+	}
+
+	cfg = cfg.normalize(origins != nil)
+
+	corsOpts := cors.Options{
+		AllowedOrigins:       cfg.AllowOrigins,
+		AllowedMethods:       cfg.AllowMethods,
+		AllowedHeaders:       cfg.AllowHeaders,
+		ExposedHeaders:       cfg.ExposeHeaders,
+		AllowCredentials:     cfg.AllowCredentials,
+		AllowPrivateNetwork:  cfg.AllowPrivateNetwork,
+		OptionsPassthrough:   cfg.OptionsPassthrough,
+		OptionsSuccessStatus: cfg.OptionsSuccessStatus,
+		MaxAge:               int(cfg.MaxAge.Seconds()),
+	}
+	var dl *debugLogger
+	if l != nil {
+		dl = newDebugLogger(l, cfg.Debug)
+		corsOpts.Logger = dl
+	} else {
+		corsOpts.Debug = cfg.Debug
+	}
+	if origins == nil {
+		origins = newStaticMatcher(cfg.AllowOrigins)
+	}
+
+	p := &Policy{
+		cfg:     cfg,
+		origins: origins,
+		headers: map[string]struct{}{},
+		log:     dl,
+		logger:  l,
+	}
+	if len(cfg.AllowPrivateNetworkOrigins) > 0 {
+		p.privateNetworkOrigins = newStaticMatcher(cfg.AllowPrivateNetworkOrigins)
+	}
+	for _, h := range cfg.AllowHeaders {
+		if h == "*" {
+			p.allHeaders = true
+		}
+		p.headers[strings.ToLower(h)] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// rs/cors compares the origins as plain strings, so the validator takes care of them unless all
+	// of them are allowed
+	switch v := p.origins.(type) {
+	case *OriginMatcher:
+		if !v.all {
+			corsOpts.AllowOriginFunc = v.Match
+		}
+	default:
+		corsOpts.AllowOriginRequestFunc = func(r *http.Request, origin string) bool {
+			ok, _ := p.origins.ValidateOrigin(r, origin)
+			return ok
+		}
+	}
+	p.cors = cors.New(corsOpts)
+
+	if p.audit == nil && cfg.Audit != nil {
+		w, err := newAuditSink(cfg.Audit)
+		if err != nil {
+			return nil, err
+		}
+		p.audit = newAuditor(w, cfg.Audit, l)
+	}
+	return p, nil
+}
+
+// normalize returns a copy of the Config with all the defaults applied
+func (c Config) normalize(customOrigins bool) Config {
+	if len(c.AllowOrigins) == 0 && !customOrigins {
+		c.AllowOrigins = []string{"*"}
+	}
+	if len(c.AllowMethods) == 0 {
+		c.AllowMethods = defaultMethods
+	}
+	if c.AutoHeaders {
+		headers := make([]string, 0, len(c.AlwaysAllowHeaders)+len(c.AllowHeaders))
+		c.AllowHeaders = append(append(headers, c.AlwaysAllowHeaders...), c.AllowHeaders...)
+		if len(c.AllowHeaders) == 0 {
+			c.AllowHeaders = defaultAutoHeaders
+		}
+	} else if len(c.AllowHeaders) == 0 {
+		c.AllowHeaders = []string{"*"}
+	}
+	if c.OptionsSuccessStatus == 0 {
+		if c.Compat == CompatGin {
+			c.OptionsSuccessStatus = http.StatusOK
+		} else {
+			c.OptionsSuccessStatus = http.StatusNoContent
+		}
+	}
+	if len(c.AllowPrivateNetworkOrigins) > 0 {
+		c.AllowPrivateNetwork = true
+	}
+	if c.Enforce && c.EnforceStatus == 0 {
+		c.EnforceStatus = http.StatusForbidden
+	}
+	return c
+}
+
+// SetDebug enables or disables the debug messages at runtime. It has no effect if the policy was
+// built without a logger.
+func (p *Policy) SetDebug(enabled bool) {
+	if p.log != nil {
+		p.log.enabled.Store(enabled)
+	}
+	if p.tenants != nil {
+		for _, tp := range p.tenants.policies() {
+			tp.SetDebug(enabled)
+		}
+	}
+}
+
+func (p *Policy) debugEnabled() bool {
+	return p.log != nil && p.log.enabled.Load()
+}
+
+// Config returns the configuration of the policy, with all the defaults applied
+func (p *Policy) Config() Config {
+	return p.cfg
+}
+
+// Handler wraps the next handler with the CORS policy. Preflights are answered without calling the
+// next handler unless the options passthrough is enabled. The decision taken for every cross-origin
+// request is added to the span of the request context, if it is recording, and the rejected ones are
+// written to the audit log, if enabled. In enforce mode, the rejected requests are answered with an
+// error and never reach the next handler. It implements the mux.HandlerMiddleware interface.
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	if p.tenants != nil {
+		return p.tenants.handler(next, p.handler(next))
+	}
+	return p.handler(next)
+}
+
+func (p *Policy) handler(next http.Handler) http.Handler {
+	h := p.cors.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if original, ok := r.Context().Value(originalRequestKey{}).(*http.Request); ok {
+			r = original
+		}
+		next.ServeHTTP(w, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		debug := p.debugEnabled()
+		if p.metrics != nil || p.audit != nil || p.cfg.Enforce || span.IsRecording() || debug {
+			d := p.evaluate(r)
+			if p.metrics != nil {
+				p.metrics.collect(d)
+			}
+			if span.IsRecording() {
+				traceDecision(span, d)
+			}
+			if debug {
+				p.log.decision(d)
+			}
+			if p.audit != nil && !d.allowed {
+				p.audit.record(r, d)
+			}
+			if p.cfg.Enforce && !d.allowed {
+				p.reject(w, d)
+				return
+			}
+		}
+		original := r
+		if p.cfg.AllowPrivateNetwork && isPreflight(r) && r.Header.Get(headerRequestPrivateNetwork) == "true" {
+			r = p.privateNetworkPreflight(w, r)
+		}
+		if r = p.filterOrigin(r); r != original {
+			r = r.WithContext(context.WithValue(r.Context(), originalRequestKey{}, original))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// originalRequestKey holds the request received by the policy when rs/cors gets a copy with some
+// headers hidden, so the next handler still gets the original one
+type originalRequestKey struct{}
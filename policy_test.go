@@ -0,0 +1,75 @@
+package cors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewPolicy_defaults(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      Config
+		expected Config
+	}{
+		{
+			name: "empty",
+			cfg:  Config{},
+			expected: Config{
+				AllowOrigins:         []string{"*"},
+				AllowMethods:         []string{"GET", "POST", "HEAD"},
+				AllowHeaders:         []string{"*"},
+				OptionsSuccessStatus: 204,
+			},
+		},
+		{
+			name: "legacy gin",
+			cfg:  Config{Compat: CompatGin},
+			expected: Config{
+				AllowOrigins:         []string{"*"},
+				AllowMethods:         []string{"GET", "POST", "HEAD"},
+				AllowHeaders:         []string{"*"},
+				OptionsSuccessStatus: 200,
+				Compat:               CompatGin,
+			},
+		},
+		{
+			name: "custom origins and auto headers",
+			cfg: Config{
+				AllowOriginsRegex:  []string{"https://.*"},
+				AllowHeaders:       []string{"X-Test"},
+				AutoHeaders:        true,
+				AlwaysAllowHeaders: []string{"Content-Type"},
+			},
+			expected: Config{
+				AllowOriginsRegex:    []string{"https://.*"},
+				AllowMethods:         []string{"GET", "POST", "HEAD"},
+				AllowHeaders:         []string{"Content-Type", "X-Test"},
+				AutoHeaders:          true,
+				AlwaysAllowHeaders:   []string{"Content-Type"},
+				OptionsSuccessStatus: 204,
+			},
+		},
+		{
+			name: "auto headers without headers",
+			cfg:  Config{AutoHeaders: true},
+			expected: Config{
+				AllowOrigins:         []string{"*"},
+				AllowMethods:         []string{"GET", "POST", "HEAD"},
+				AllowHeaders:         []string{"Accept", "Content-Type", "X-Requested-With"},
+				AutoHeaders:          true,
+				OptionsSuccessStatus: 204,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewPolicy(tc.cfg, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(p.Config(), tc.expected) {
+				t.Errorf("unexpected config.\nhave: %+v\nwant: %+v", p.Config(), tc.expected)
+			}
+		})
+	}
+}
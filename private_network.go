@@ -0,0 +1,75 @@
+package cors
+
+import (
+	"net/http"
+	"regexp"
+)
+
+const (
+	headerRequestPrivateNetwork = "Access-Control-Request-Private-Network"
+	headerPrivateNetworkName    = "Private-Network-Access-Name"
+	headerPrivateNetworkID      = "Private-Network-Access-ID"
+)
+
+var (
+	privateNetworkAccessNameRe = regexp.MustCompile(`^[a-z0-9_\-.]{1,248}$`)
+	privateNetworkAccessIDRe   = regexp.MustCompile(`^[0-9A-Fa-f]{2}(:[0-9A-Fa-f]{2}){5}$`)
+)
+
+// validPrivateNetworkAccessName checks the device name against the format required by the private
+// network access spec
+func validPrivateNetworkAccessName(name string) bool {
+	return privateNetworkAccessNameRe.MatchString(name)
+}
+
+// validPrivateNetworkAccessID checks the device id against the format required by the private network
+// access spec
+func validPrivateNetworkAccessID(id string) bool {
+	return privateNetworkAccessIDRe.MatchString(id)
+}
+
+// privateNetworkAllowed reports whether the origin can access the private network
+func (p *Policy) privateNetworkAllowed(origin string) bool {
+	if !p.cfg.AllowPrivateNetwork {
+		return false
+	}
+	return p.privateNetworkOrigins == nil || p.privateNetworkOrigins.Match(origin)
+}
+
+func (p *Policy) privateNetworkRule(ok bool) string {
+	switch {
+	case !p.cfg.AllowPrivateNetwork:
+		return "allow_private_network is disabled"
+	case !ok:
+		return "not in allow_private_network_origins"
+	case p.privateNetworkOrigins != nil:
+		return "allow_private_network_origins"
+	}
+	return "allow_private_network"
+}
+
+// privateNetworkPreflight prepares the preflights requesting access to the private network before
+// handing them to rs/cors, which grants the access to every allowed origin. For the origins out of
+// the private network allowlist, the request is replaced by a copy without the access request, so
+// the preflight is answered without granting it. For the rest, the device name and id are added to
+// the response if the preflight is going to be allowed.
+func (p *Policy) privateNetworkPreflight(w http.ResponseWriter, r *http.Request) *http.Request {
+	if !p.privateNetworkAllowed(r.Header.Get("Origin")) {
+		r = r.Clone(r.Context())
+		r.Header.Del(headerRequestPrivateNetwork)
+		return r
+	}
+	if p.cfg.PrivateNetworkAccessName == "" && p.cfg.PrivateNetworkAccessID == "" {
+		return r
+	}
+	if !p.evaluate(r).allowed {
+		return r
+	}
+	if p.cfg.PrivateNetworkAccessName != "" {
+		w.Header().Set(headerPrivateNetworkName, p.cfg.PrivateNetworkAccessName)
+	}
+	if p.cfg.PrivateNetworkAccessID != "" {
+		w.Header().Set(headerPrivateNetworkID, p.cfg.PrivateNetworkAccessID)
+	}
+	return r
+}
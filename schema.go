@@ -0,0 +1,350 @@
+package cors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v3/config"
+)
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema (draft 2020-12) of the CORS namespace, describing every option with
+// its type, default value and constraints. It can be used by editors and config linters.
+func Schema() []byte {
+	return append([]byte(nil), schemaJSON...)
+}
+
+// schemaKeywords lists the keywords understood by the schema validator. The rest of the keywords
+// (descriptions, defaults, examples...) are annotations.
+var schemaKeywords = []string{
+	"$ref", "type", "enum", "const", "pattern", "minimum", "maximum", "required", "properties",
+	"additionalProperties", "propertyNames", "items", "anyOf", "not",
+}
+
+var (
+	schemaOnce sync.Once
+	schemaRoot map[string]interface{}
+	// schemaPatterns caches the compiled patterns of the schema
+	schemaPatterns sync.Map
+)
+
+func loadSchema() map[string]interface{} {
+	schemaOnce.Do(func() {
+		if err := json.Unmarshal(schemaJSON, &schemaRoot); err != nil {
+			panic(fmt.Sprintf("invalid embedded schema: %s", err))
+		}
+	})
+	return schemaRoot
+}
+
+// ValidateSchema validates the raw CORS namespace of the extra config against the schema. It returns
+// ErrNoConfig if the namespace is not present and a ValidationErrors with every violation otherwise.
+// The unknown keys are reported wrapping ErrUnknownKey, whatever the strict mode is.
+//
+// The schema only checks the shape of the values: ParseConfig runs it and adds the semantic checks
+// (valid origins, patterns and regular expressions...) on top of it.
+func ValidateSchema(e config.ExtraConfig) error {
+	v, ok := e[Namespace]
+	if !ok {
+		return ErrNoConfig
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return ValidationErrors{{Path: Namespace, Got: jsonType(v), Expected: "object"}}
+	}
+	errs := schemaValidator{root: loadSchema()}.validate(loadSchema(), v, "", Namespace)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+// validate checks the value at the key (relative to the namespace) and the path (under the extra
+// config) against the schema
+func (s schemaValidator) validate(schema map[string]interface{}, v interface{}, key, path string) ValidationErrors {
+	schema = s.resolve(schema)
+	fail := func(err error) ValidationErrors {
+		return ValidationErrors{{Key: key, Path: path, Got: jsonType(v), Expected: s.title(schema), Err: err}}
+	}
+
+	if t, ok := schema["type"].(string); ok && !schemaType(t, v) {
+		return fail(nil)
+	}
+	if c, ok := schema["const"]; ok && !schemaEqual(c, v) {
+		return fail(nil)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !schemaIn(enum, v) {
+		for _, e := range enum {
+			if jsonType(e) == jsonType(v) {
+				return fail(fmt.Errorf("unknown value %s", schemaValue(v)))
+			}
+		}
+		return fail(nil)
+	}
+	if expr, ok := schema["pattern"].(string); ok {
+		if str, ok := v.(string); ok && !schemaPattern(expr).MatchString(str) {
+			return fail(fmt.Errorf("%q does not match %q", str, expr))
+		}
+	}
+	if n, ok := schemaNumber(v); ok {
+		if minimum, ok := schema["minimum"].(float64); ok && n < minimum {
+			return fail(fmt.Errorf("%v is lower than %v", n, minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && n > maximum {
+			return fail(fmt.Errorf("%v is greater than %v", n, maximum))
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(s.validate(not, v, key, path)) == 0 {
+		return fail(nil)
+	}
+	if branches, ok := schema["anyOf"].([]interface{}); ok {
+		if errs := s.anyOf(branches, v, key, path); errs != nil {
+			if errs[0].Path == path {
+				return fail(nil)
+			}
+			return errs
+		}
+	}
+
+	var errs ValidationErrors
+	switch v := v.(type) {
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, s.validate(items, item, key, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		errs = s.object(schema, v, key, path)
+	}
+	return errs
+}
+
+// anyOf returns nil if any of the branches accepts the value. Otherwise, it returns the errors of the
+// first branch matching the type of the value, so the offending entries are reported, or a single
+// error at the path if none of them does.
+func (s schemaValidator) anyOf(branches []interface{}, v interface{}, key, path string) ValidationErrors {
+	var deeper ValidationErrors
+	for _, b := range branches {
+		branch, _ := b.(map[string]interface{})
+		errs := s.validate(branch, v, key, path)
+		if len(errs) == 0 {
+			return nil
+		}
+		if deeper == nil && errs[0].Path != path {
+			deeper = errs
+		}
+	}
+	if deeper != nil {
+		return deeper
+	}
+	return ValidationErrors{{Path: path}}
+}
+
+func (s schemaValidator) object(schema, v map[string]interface{}, key, path string) ValidationErrors {
+	child := func(name string) (string, string) {
+		if key == "" {
+			return name, fieldPath(name)
+		}
+		return key + "." + name, path + "." + name
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs ValidationErrors
+	properties, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := v[name]; ok {
+				continue
+			}
+			k, p := child(name)
+			prop, _ := properties[name].(map[string]interface{})
+			errs = append(errs, &FieldError{Key: k, Path: p, Got: "null", Expected: s.title(s.resolve(prop)), Err: errors.New("required")})
+		}
+	}
+
+	known := make([]string, 0, len(properties))
+	for name := range properties {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	propertyNames, _ := schema["propertyNames"].(map[string]interface{})
+	for _, name := range names {
+		k, p := child(name)
+		if propertyNames != nil && len(s.validate(propertyNames, name, k, p)) > 0 {
+			errs = append(errs, &FieldError{Key: k, Path: p, Got: jsonType(v[name]), Err: ErrUnknownKey})
+			continue
+		}
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			errs = append(errs, s.validate(prop, v[name], k, p)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, &FieldError{Key: k, Path: p, Got: jsonType(v[name]), Err: ErrUnknownKey, Suggestion: suggestKey(name, known)})
+			}
+		case map[string]interface{}:
+			errs = append(errs, s.validate(additional, v[name], k, p)...)
+		}
+	}
+	return errs
+}
+
+// resolve merges the schema referenced by $ref (a local JSON pointer) with the rest of the keywords
+func (s schemaValidator) resolve(schema map[string]interface{}) map[string]interface{} {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	target := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		target, _ = target[token].(map[string]interface{})
+	}
+	target = s.resolve(target)
+	merged := make(map[string]interface{}, len(schema)+len(target))
+	for k, v := range target {
+		merged[k] = v
+	}
+	for k, v := range schema {
+		if k != "$ref" {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// title describes the expected value in the errors, as the parser does
+func (s schemaValidator) title(schema map[string]interface{}) string {
+	if t, ok := schema["title"].(string); ok {
+		return t
+	}
+	if t, ok := schema["type"].(string); ok {
+		return t
+	}
+	return "value"
+}
+
+func schemaType(t string, v interface{}) bool {
+	switch t {
+	case "integer":
+		n, ok := schemaNumber(v)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := schemaNumber(v)
+		return ok
+	default:
+		return jsonType(v) == t
+	}
+}
+
+func schemaNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func schemaEqual(a, b interface{}) bool {
+	if x, ok := schemaNumber(a); ok {
+		y, ok := schemaNumber(b)
+		return ok && x == y
+	}
+	if jsonType(a) != jsonType(b) {
+		return false
+	}
+	switch a.(type) {
+	case string, bool, nil:
+		return a == b
+	}
+	return false
+}
+
+func schemaIn(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if schemaEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func schemaPattern(expr string) *regexp.Regexp {
+	if re, ok := schemaPatterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	schemaPatterns.Store(expr, re)
+	return re
+}
+
+// mergeSchemaErrors adds to the errors of the parser the violations of the schema at the paths it
+// did not report, so its more detailed messages are kept. The unknown keys are only reported in
+// strict mode.
+func mergeSchemaErrors(errs, schemaErrs ValidationErrors, strict bool) ValidationErrors {
+	reported := errs[:len(errs):len(errs)]
+	for _, se := range schemaErrs {
+		if !strict && errors.Is(se, ErrUnknownKey) {
+			continue
+		}
+		dup := false
+		for _, e := range reported {
+			if related(se.Path, e.Path) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			errs = append(errs, se)
+		}
+	}
+	return errs
+}
+
+// related reports whether the paths are equal or one of them is inside the other
+func related(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a == b || strings.HasPrefix(b, a+".") || strings.HasPrefix(b, a+"[")
+}
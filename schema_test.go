@@ -0,0 +1,274 @@
+package cors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func schemaProperties(t *testing.T, schema map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	props, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("the schema has no properties: %v", schema)
+	}
+	return props
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCopy(list []string) []string {
+	out := append([]string{}, list...)
+	sort.Strings(out)
+	return out
+}
+
+func TestSchema_keys(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	root := schemaProperties(t, schema)
+	for name, tc := range map[string]struct {
+		props map[string]interface{}
+		known []string
+	}{
+		"root":  {root, knownKeys},
+		"audit": {schemaProperties(t, root["audit"].(map[string]interface{})), auditKeys},
+		"admin": {schemaProperties(t, root["admin"].(map[string]interface{})), adminKeys},
+	} {
+		if got, want := sortedKeys(tc.props), sortedCopy(tc.known); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the schema properties %v do not match the known keys %v", name, got, want)
+		}
+	}
+
+	tenant := root["tenants"].(map[string]interface{})["additionalProperties"].(map[string]interface{})
+	var excluded []string
+	for _, k := range tenant["propertyNames"].(map[string]interface{})["not"].(map[string]interface{})["enum"].([]interface{}) {
+		excluded = append(excluded, k.(string))
+	}
+	if !reflect.DeepEqual(sortedCopy(excluded), sortedCopy(tenantKeys)) {
+		t.Errorf("the keys excluded from the tenants %v do not match %v", excluded, tenantKeys)
+	}
+
+	var check func(path string, v interface{})
+	check = func(path string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				if k == "properties" || k == "$defs" {
+					for name, p := range e.(map[string]interface{}) {
+						check(path+"."+name, p)
+					}
+					continue
+				}
+				if k == "examples" || k == "default" || k == "enum" || k == "const" {
+					continue
+				}
+				if !contains(schemaKeywords, k) && !contains([]string{"$schema", "$id", "title", "description"}, k) {
+					t.Errorf("%s: keyword %q not supported by the validator", path, k)
+				}
+				check(path+"."+k, e)
+			}
+		case []interface{}:
+			for i, e := range v {
+				check(fmt.Sprintf("%s[%d]", path, i), e)
+			}
+		}
+	}
+	check("#", schema)
+}
+
+// TestSchema_examples checks that the examples of every option are accepted by the schema and
+// the parser and change the parsed configuration
+func TestSchema_examples(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal(Schema(), &schema)
+	empty, _ := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{}})
+
+	for name, p := range schemaProperties(t, schema) {
+		examples, _ := p.(map[string]interface{})["examples"].([]interface{})
+		if len(examples) == 0 {
+			t.Errorf("%s: no examples", name)
+		}
+		for _, example := range examples {
+			e := map[string]interface{}{Namespace: map[string]interface{}{name: example}}
+			if err := ValidateSchema(e); err != nil {
+				t.Errorf("%s: the example %v is rejected by the schema: %v", name, example, err)
+			}
+			cfg, err := ParseConfig(e)
+			if err != nil {
+				t.Errorf("%s: the example %v is rejected by the parser: %v", name, example, err)
+			}
+			if reflect.DeepEqual(cfg, empty) {
+				t.Errorf("%s: the example %v is ignored by the parser", name, example)
+			}
+		}
+	}
+}
+
+// TestSchema_types checks that the schema accepts the same types of values as the parser
+func TestSchema_types(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal(Schema(), &schema)
+	root := schemaProperties(t, schema)
+	candidates := []interface{}{true, 42.5, 404.0, "x", "auto", "1m", []interface{}{}, []interface{}{1.0}, map[string]interface{}{}, nil}
+
+	check := func(name string, ns func(v interface{}) map[string]interface{}) {
+		for _, v := range candidates {
+			schemaErr := ValidateSchema(map[string]interface{}{Namespace: ns(v)})
+			_, errs := parseNamespace(ns(v))
+			if (schemaErr == nil) != (len(errs) == 0) {
+				t.Errorf("%s = %#v: schema error %v, parser errors %v", name, v, schemaErr, errs)
+			}
+		}
+	}
+	for name := range root {
+		check(name, func(v interface{}) map[string]interface{} { return map[string]interface{}{name: v} })
+	}
+	for _, name := range auditKeys {
+		check("audit."+name, func(v interface{}) map[string]interface{} {
+			return map[string]interface{}{"audit": map[string]interface{}{name: v, "output": "stderr"}}
+		})
+	}
+}
+
+// TestSchema_defaults checks that the documented defaults are the ones applied by the policies
+func TestSchema_defaults(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal(Schema(), &schema)
+	root := schemaProperties(t, schema)
+
+	p, err := NewPolicy(Config{Enforce: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := p.Config().toMap()
+	delete(applied, "enforce")
+	applied["allow_origins_file_interval"] = DefaultOriginsFileInterval.String()
+	applied["tenant_fallback"] = TenantFallbackDefault
+	audit := map[string]interface{}{"output": AuditStdout, "dedup_window": DefaultAuditDedupWindow.String()}
+
+	for name, props := range map[string]map[string]interface{}{"": root, "audit.": schemaProperties(t, root["audit"].(map[string]interface{}))} {
+		for k, prop := range props {
+			def, ok := prop.(map[string]interface{})["default"]
+			if !ok {
+				continue
+			}
+			want, ok := applied[k]
+			if name != "" {
+				want, ok = audit[k]
+			}
+			if !ok {
+				// the options omitted by toMap are disabled
+				want = false
+			}
+			if d, err := time.ParseDuration(fmt.Sprint(def)); err == nil {
+				def = d.String()
+			}
+			got, _ := json.Marshal(def)
+			wanted, _ := json.Marshal(want)
+			if string(got) != string(wanted) {
+				t.Errorf("%s%s: the documented default %s does not match the applied one %s", name, k, got, wanted)
+			}
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{
+			name: "valid",
+			cfg: `{"allow_origins":["https://example.com"],"allow_methods":"auto","max_age":"12h","audit":{"output":"stderr"},
+				"tenants":{"api.example.com":{"allow_credentials":true}},"admin":{"port":8081,"token":"secret"}}`,
+		},
+		{
+			name: "invalid types",
+			cfg:  `{"allow_methods":["GET",1],"allow_headers":42,"debug":"yes"}`,
+			err: `3 invalid value(s) in security/cors: security/cors.allow_headers: got number, expected array of strings or "auto"; ` +
+				`security/cors.allow_methods[1]: got number, expected string; security/cors.debug: got string, expected boolean`,
+		},
+		{
+			name: "constraints",
+			cfg:  `{"options_success_status":42,"max_age":"1 day","compat":"echo"}`,
+			err: `3 invalid value(s) in security/cors: security/cors.compat: invalid compatibility mode: unknown value "echo"; ` +
+				`security/cors.max_age: invalid duration string: "1 day" does not match "^[-+]?(0|([0-9]*(\\.[0-9]*)?(ns|us|µs|μs|ms|s|m|h))+)$"; ` +
+				`security/cors.options_success_status: invalid status code: 42 is lower than 100`,
+		},
+		{
+			name: "unknown keys",
+			cfg:  `{"allow_origin":["*"],"audit":{"outptu":"file"},"tenants":{"api.example.com":{"admin":{}}}}`,
+			err: `3 invalid value(s) in security/cors: security/cors.allow_origin: unknown key, did you mean "allow_origins"?; ` +
+				`security/cors.audit.outptu: unknown key, did you mean "output"?; security/cors.tenants.api.example.com.admin: unknown key`,
+		},
+		{
+			name: "admin",
+			cfg:  `{"admin":{"path":"__cors"}}`,
+			err: `2 invalid value(s) in security/cors: security/cors.admin.token: invalid admin token: required; ` +
+				`security/cors.admin.path: invalid admin path: "__cors" does not match "^/"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ns map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.cfg), &ns); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateSchema(map[string]interface{}{Namespace: ns})
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("unexpected error:\n%v\nwant:\n%s", err, tc.err)
+			}
+		})
+	}
+
+	if err := ValidateSchema(map[string]interface{}{}); err != ErrNoConfig {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseConfig_schema(t *testing.T) {
+	_, err := ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"allow_origins":          []interface{}{"https://example.com/path"},
+		"options_success_status": 42,
+		"allow_origin":           []interface{}{"*"},
+	}})
+	want := "2 invalid value(s) in security/cors: " +
+		`security/cors.allow_origins[0]: invalid origin: a path (or a trailing slash) is not allowed; ` +
+		"security/cors.options_success_status: invalid status code: 42 is not between 100 and 599"
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || errs[1].Key != "options_success_status" {
+		t.Errorf("unexpected errors: %#v", err)
+	}
+
+	_, err = ParseConfig(map[string]interface{}{Namespace: map[string]interface{}{
+		"strict":       true,
+		"allow_origin": []interface{}{"*"},
+	}})
+	if err == nil || strings.Count(err.Error(), "allow_origin:") != 1 {
+		t.Errorf("the unknown key should be reported once: %v", err)
+	}
+}
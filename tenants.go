@@ -0,0 +1,189 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// The supported values of the tenant fallback
+const (
+	// TenantFallbackDefault applies the options out of the tenants to the unknown hosts
+	TenantFallbackDefault = "default"
+	// TenantFallbackReject rejects the cross-origin requests to the unknown hosts
+	TenantFallbackReject = "reject"
+)
+
+// tenantKeys are the options accepted only at the top level of the CORS namespace
+var tenantKeys = []string{"tenants", "tenant_header", "tenant_fallback", "admin"}
+
+// parseTenants parses the policies of the tenants, merging every one of them over the base options.
+// The errors of the inherited values are not reported again.
+func parseTenants(p *parser, base map[string]interface{}) map[string]Config {
+	known := make([]string, 0, len(knownKeys))
+	for _, k := range knownKeys {
+		if !contains(tenantKeys, k) {
+			known = append(known, k)
+		}
+	}
+
+	hosts := make([]string, 0, len(p.data))
+	for host := range p.data {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	tenants := map[string]Config{}
+	for _, host := range hosts {
+		tp, ok := p.object(host)
+		if !ok {
+			continue
+		}
+		pattern, err := normalizeHostPattern(host)
+		if err != nil {
+			p.fail(host, p.path(host), host, "host pattern", err)
+			continue
+		}
+
+		merged := make(map[string]interface{}, len(base)+len(tp.data))
+		for k, v := range base {
+			if !contains(tenantKeys, k) {
+				merged[k] = v
+			}
+		}
+		for k, v := range tp.data {
+			merged[k] = v
+		}
+		mp := &parser{data: merged, prefix: tp.prefix, errs: &ValidationErrors{}}
+		cfg := parseConfig(mp, known)
+		for _, e := range *mp.errs {
+			k, _, _ := strings.Cut(strings.TrimPrefix(e.Key, tp.prefix), ".")
+			if _, own := tp.data[k]; own {
+				*p.errs = append(*p.errs, e)
+			}
+		}
+		for _, k := range known {
+			if _, own := tp.data[k]; !own {
+				cfg.inherited = append(cfg.inherited, k)
+			}
+		}
+		tenants[pattern] = cfg
+	}
+	return tenants
+}
+
+// normalizeHostPattern validates a host pattern, returning it in lowercase and with the
+// internationalized names converted to punycode. A leading "*." matches one or more labels.
+func normalizeHostPattern(pattern string) (string, error) {
+	host, subdomains := strings.CutPrefix(pattern, "*.")
+	if host == "" || strings.ContainsAny(host, "*:/@") {
+		return "", errors.New(`a host name, optionally starting with "*.", is required`)
+	}
+	host, err := idnaProfile.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	if subdomains {
+		return "*." + host, nil
+	}
+	return host, nil
+}
+
+// tenantRouter selects the policy of the tenant by the host of the request
+type tenantRouter struct {
+	header     string
+	exact      map[string]*Policy
+	subdomains map[string]*Policy
+	fallback   *Policy
+}
+
+func newTenantRouter(header string) *tenantRouter {
+	return &tenantRouter{
+		header:     header,
+		exact:      map[string]*Policy{},
+		subdomains: map[string]*Policy{},
+	}
+}
+
+func (t *tenantRouter) add(pattern string, p *Policy) {
+	if host, ok := strings.CutPrefix(pattern, "*."); ok {
+		t.subdomains[host] = p
+		return
+	}
+	t.exact[pattern] = p
+}
+
+// policy returns the policy of the host: the one declared for the host itself or, if there is none,
+// the one of the closest parent domain declared with a wildcard
+func (t *tenantRouter) policy(host string) (*Policy, bool) {
+	if p, ok := t.exact[host]; ok {
+		return p, true
+	}
+	for i := strings.IndexByte(host, '.'); i > 0; {
+		suffix := host[i+1:]
+		if p, ok := t.subdomains[suffix]; ok {
+			return p, true
+		}
+		j := strings.IndexByte(suffix, '.')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return nil, false
+}
+
+// host returns the host used to select the tenant of the request, without the port
+func (t *tenantRouter) host(r *http.Request) string {
+	host := r.Host
+	if t.header != "" {
+		if h := r.Header.Get(t.header); h != "" {
+			host = h
+		}
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func (t *tenantRouter) handler(next, fallback http.Handler) http.Handler {
+	handlers := make(map[*Policy]http.Handler, len(t.exact)+len(t.subdomains))
+	for _, p := range t.exact {
+		handlers[p] = p.handler(next)
+	}
+	for _, p := range t.subdomains {
+		handlers[p] = p.handler(next)
+	}
+	if t.fallback != nil {
+		fallback = t.fallback.handler(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := t.policy(t.host(r)); ok {
+			handlers[p].ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+func (t *tenantRouter) policies() []*Policy {
+	out := make([]*Policy, 0, len(t.exact)+len(t.subdomains)+1)
+	for _, p := range t.exact {
+		out = append(out, p)
+	}
+	for _, p := range t.subdomains {
+		out = append(out, p)
+	}
+	if t.fallback != nil {
+		out = append(out, t.fallback)
+	}
+	return out
+}
+
+// rejectOrigins is the origin validator of the policy applied to the unknown hosts when they are
+// rejected
+var rejectOrigins = OriginValidatorFunc(func(*http.Request, string) (bool, string) {
+	return false, "unknown tenant"
+})
@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_tenants(t *testing.T) {
+	sampleCfg := map[string]interface{}{}
+	serialized := []byte(`{ "security/cors": {
+			"allow_origins": [ "https://www.example.com" ],
+			"allow_methods": [ "GET" ],
+			"max_age": 3600,
+			"strict": true,
+			"tenant_header": "X-Forwarded-Host",
+			"tenants": {
+				"api.customer-a.com": {
+					"allow_origins": [ "https://app.customer-a.com" ]
+				},
+				"*.Customer-B.com": {
+					"allow_methods": [ "GET", "PUT" ],
+					"allow_origins": 42,
+					"tenants": {}
+				},
+				"api.customer-c.com/v1": {}
+			}
+			}
+		}`)
+	if err := json.Unmarshal(serialized, &sampleCfg); err != nil {
+		t.Error(err)
+		return
+	}
+	cfg, err := ParseConfig(sampleCfg)
+	if err == nil {
+		t.Error("an error was expected")
+		return
+	}
+	for _, msg := range []string{
+		"security/cors.max_age: got number, expected duration string",
+		"security/cors.tenants.*.Customer-B.com.allow_origins: got number, expected array of strings",
+		"security/cors.tenants.*.Customer-B.com.tenants: unknown key",
+		"security/cors.tenants.api.customer-c.com/v1: invalid host pattern",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q not reported: %v", msg, err)
+		}
+	}
+	if n := strings.Count(err.Error(), "max_age"); n != 1 {
+		t.Errorf("the errors of the inherited values should be reported once: %v", err)
+	}
+
+	if cfg.TenantHeader != "X-Forwarded-Host" || len(cfg.Tenants) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+		return
+	}
+	a := cfg.Tenants["api.customer-a.com"]
+	if strings.Join(a.AllowOrigins, " ") != "https://app.customer-a.com" || strings.Join(a.AllowMethods, " ") != "GET" {
+		t.Errorf("unexpected tenant config: %+v", a)
+	}
+	b := cfg.Tenants["*.customer-b.com"]
+	if len(b.AllowOrigins) != 0 || strings.Join(b.AllowMethods, " ") != "GET PUT" || !b.Strict {
+		t.Errorf("unexpected tenant config: %+v", b)
+	}
+}
+
+func TestPolicy_tenants(t *testing.T) {
+	tenants := map[string]Config{
+		"api.customer-a.com": {AllowOrigins: []string{"https://app.customer-a.com"}},
+		"*.customer-b.com":   {AllowOrigins: []string{"https://app.customer-b.com"}},
+	}
+	for _, tc := range []struct {
+		name     string
+		fallback string
+		header   string
+		host     string
+		origin   string
+		allowed  bool
+	}{
+		{name: "exact host", host: "api.customer-a.com", origin: "https://app.customer-a.com", allowed: true},
+		{name: "exact host with port", host: "API.customer-a.com:8080", origin: "https://app.customer-a.com", allowed: true},
+		{name: "origin of another tenant", host: "api.customer-a.com", origin: "https://app.customer-b.com"},
+		{name: "subdomain", host: "eu.api.customer-b.com", origin: "https://app.customer-b.com", allowed: true},
+		{name: "parent domain", host: "customer-b.com", origin: "https://app.customer-b.com"},
+		{name: "header", header: "api.customer-a.com", host: "internal", origin: "https://app.customer-a.com", allowed: true},
+		{name: "default fallback", host: "unknown.com", origin: "https://www.example.com", allowed: true},
+		{name: "default fallback with a tenant origin", host: "unknown.com", origin: "https://app.customer-a.com"},
+		{name: "reject fallback", fallback: TenantFallbackReject, host: "unknown.com", origin: "https://www.example.com"},
+		{
+			name:     "reject fallback for a known host",
+			fallback: TenantFallbackReject,
+			host:     "api.customer-a.com",
+			origin:   "https://app.customer-a.com",
+			allowed:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewPolicy(Config{
+				AllowOrigins:   []string{"https://www.example.com"},
+				Tenants:        tenants,
+				TenantHeader:   "X-Forwarded-Host",
+				TenantFallback: tc.fallback,
+			}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+			req.Host = tc.host
+			req.Header.Add("Origin", tc.origin)
+			if tc.header != "" {
+				req.Header.Add("X-Forwarded-Host", tc.header)
+			}
+			res := httptest.NewRecorder()
+			p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(res, req)
+			if allowed := res.Header().Get("Access-Control-Allow-Origin") == tc.origin; allowed != tc.allowed {
+				t.Errorf("unexpected decision: %v", allowed)
+			}
+		})
+	}
+}
+
+func TestConfig_Lint_tenants(t *testing.T) {
+	findings := Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		Tenants: map[string]Config{
+			"api.customer-a.com": {AllowCredentials: true, AllowHeaders: []string{"Authorization"}},
+		},
+	}.Lint()
+	want := "error [credentials-wildcard-origin] tenant api.customer-a.com: " +
+		"allow_credentials with a wildcard origin lets any site perform authenticated requests"
+	if len(findings) != 1 || findings[0].String() != want {
+		t.Errorf("unexpected findings: %v", findings)
+	}
+}
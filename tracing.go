@@ -0,0 +1,37 @@
+package cors
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceDecision adds the decision to the span as attributes, so they can be used to filter the
+// traces, and as an event, so it is placed in the timeline of the request
+func traceDecision(span trace.Span, d decision) {
+	if d.origin == "" {
+		return
+	}
+
+	result := "allowed"
+	if !d.allowed {
+		result = "rejected"
+	}
+	attrs := []attribute.KeyValue{
+		attribute.Bool("cors.preflight", d.preflight),
+		attribute.String("cors.origin", d.origin),
+		attribute.String("cors.request.method", d.method),
+		attribute.String("cors.decision", result),
+	}
+	if d.headers != "" {
+		attrs = append(attrs, attribute.String("cors.request.headers", d.headers))
+	}
+	if d.rule != "" {
+		attrs = append(attrs, attribute.String("cors.matched_rule", d.rule))
+	}
+	if d.reason != ReasonNone {
+		attrs = append(attrs, attribute.String("cors.reason", string(d.reason)))
+	}
+
+	span.SetAttributes(attrs...)
+	span.AddEvent("cors.decision", trace.WithAttributes(attrs...))
+}
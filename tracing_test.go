@@ -0,0 +1,88 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPolicy_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	p, err := NewPolicy(Config{
+		AllowOrigins:      []string{"https://a.example.com"},
+		AllowOriginsRegex: []string{`https://pr-\d+\.example\.com`},
+		AllowHeaders:      []string{"X-Test"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, headers := range []map[string]string{
+		{"Origin": "https://pr-42.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "x-test"},
+		{"Origin": "https://b.example.com"},
+		{},
+	} {
+		ctx, span := tracer.Start(context.Background(), "request")
+		method := "GET"
+		if headers["Access-Control-Request-Method"] != "" {
+			method = "OPTIONS"
+		}
+		req, _ := http.NewRequestWithContext(ctx, method, "https://example.com/foo", http.NoBody)
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		span.End()
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Errorf("unexpected number of spans: %d", len(spans))
+		return
+	}
+
+	assertAttributes(t, spans[0].Attributes(), map[attribute.Key]attribute.Value{
+		"cors.preflight":       attribute.BoolValue(true),
+		"cors.origin":          attribute.StringValue("https://pr-42.example.com"),
+		"cors.request.method":  attribute.StringValue("GET"),
+		"cors.request.headers": attribute.StringValue("x-test"),
+		"cors.decision":        attribute.StringValue("allowed"),
+		"cors.matched_rule":    attribute.StringValue(`^(?:https://pr-\d+\.example\.com)$`),
+	})
+	if events := spans[0].Events(); len(events) != 1 || events[0].Name != "cors.decision" {
+		t.Errorf("unexpected events: %v", events)
+	}
+
+	assertAttributes(t, spans[1].Attributes(), map[attribute.Key]attribute.Value{
+		"cors.preflight":      attribute.BoolValue(false),
+		"cors.origin":         attribute.StringValue("https://b.example.com"),
+		"cors.request.method": attribute.StringValue("GET"),
+		"cors.decision":       attribute.StringValue("rejected"),
+		"cors.reason":         attribute.StringValue("origin"),
+	})
+
+	if attrs := spans[2].Attributes(); len(attrs) != 0 {
+		t.Errorf("requests without origin should not be traced: %v", attrs)
+	}
+}
+
+func assertAttributes(t *testing.T, attrs []attribute.KeyValue, expected map[attribute.Key]attribute.Value) {
+	t.Helper()
+	if len(attrs) != len(expected) {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	for _, kv := range attrs {
+		if v, ok := expected[kv.Key]; !ok || v != kv.Value {
+			t.Errorf("unexpected attribute %s: %s", kv.Key, kv.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+// OriginValidator decides whether the origin of a cross-origin request is allowed. The reason is the
+// rule allowing the origin or an explanation of the rejection, and it is only used for debugging.
+// Implementations must be safe for concurrent use and, since they are consulted on every cross-origin
+// request (sometimes more than once), they should cache any expensive lookup.
+type OriginValidator interface {
+	ValidateOrigin(r *http.Request, origin string) (allowed bool, reason string)
+}
+
+// OriginValidatorFunc adapts a function to the OriginValidator interface
+type OriginValidatorFunc func(r *http.Request, origin string) (bool, string)
+
+// ValidateOrigin implements the OriginValidator interface
+func (f OriginValidatorFunc) ValidateOrigin(r *http.Request, origin string) (bool, string) {
+	return f(r, origin)
+}
+
+// OriginValidatorFactory builds an OriginValidator from the configuration. The options specific to
+// the validator are available in the OriginValidatorConfig field.
+type OriginValidatorFactory func(cfg Config, l logging.Logger) (OriginValidator, error)
+
+// The names of the built-in origin validators
+const (
+	// OriginValidatorStatic checks the origins against allow_origins and allow_origins_patterns
+	OriginValidatorStatic = "static"
+	// OriginValidatorRegex checks the origins against allow_origins_regex
+	OriginValidatorRegex = "regex"
+	// OriginValidatorFile checks the origins against the ones listed in allow_origins_file
+	OriginValidatorFile = "file"
+)
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]OriginValidatorFactory{
+		OriginValidatorStatic: func(cfg Config, l logging.Logger) (OriginValidator, error) {
+			return newOriginMatcher(Config{AllowOrigins: cfg.AllowOrigins, AllowOriginsPatterns: cfg.AllowOriginsPatterns}, l)
+		},
+		OriginValidatorRegex: func(cfg Config, l logging.Logger) (OriginValidator, error) {
+			return newOriginMatcher(Config{AllowOriginsRegex: cfg.AllowOriginsRegex}, l)
+		},
+		OriginValidatorFile: func(cfg Config, l logging.Logger) (OriginValidator, error) {
+			if cfg.AllowOriginsFile == "" {
+				return nil, fmt.Errorf("the %s origin validator requires allow_origins_file", OriginValidatorFile)
+			}
+			return newOriginMatcher(Config{
+				AllowOriginsFile:         cfg.AllowOriginsFile,
+				AllowOriginsFileInterval: cfg.AllowOriginsFileInterval,
+			}, l)
+		},
+	}
+)
+
+// RegisterOriginValidator makes an origin validator available under the name, so it can be selected
+// with the origin_validator option. Registering a name twice replaces the previous factory.
+func RegisterOriginValidator(name string, f OriginValidatorFactory) {
+	validatorsMu.Lock()
+	validators[name] = f
+	validatorsMu.Unlock()
+}
+
+// OriginValidators returns the names of the registered origin validators
+func OriginValidators() []string {
+	validatorsMu.RLock()
+	names := make([]string, 0, len(validators))
+	for name := range validators {
+		names = append(names, name)
+	}
+	validatorsMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// NewOriginValidator builds the origin validator registered under the name
+func NewOriginValidator(name string, cfg Config, l logging.Logger) (OriginValidator, error) {
+	validatorsMu.RLock()
+	f, ok := validators[name]
+	validatorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown origin validator %q", name)
+	}
+	return f(cfg, l)
+}
+
+// WithOriginValidator makes the policy consult the validator instead of the allowed origins of the
+// configuration
+func WithOriginValidator(v OriginValidator) Option {
+	return func(p *Policy) {
+		p.origins = v
+		p.customOrigins = true
+	}
+}
+
+// newOriginMatcher returns the matcher of the allowed origins in the Config, even if they are just a
+// static list
+func newOriginMatcher(cfg Config, l logging.Logger) (*OriginMatcher, error) {
+	m, err := NewOriginMatcher(cfg, l)
+	if err != nil || m != nil {
+		return m, err
+	}
+	return newStaticMatcher(cfg.AllowOrigins), nil
+}
+
+// ValidateOrigin implements the OriginValidator interface, so the allowed origins of the Config are
+// just another validator
+func (m *OriginMatcher) ValidateOrigin(_ *http.Request, origin string) (bool, string) {
+	rule, ok := m.MatchRule(origin)
+	return ok, rule
+}
@@ -0,0 +1,141 @@
+package cors
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v3/logging"
+)
+
+func TestNewOriginValidator_builtins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.txt")
+	if err := os.WriteFile(path, []byte("https://file.example.com\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	cfg := Config{
+		AllowOrigins:         []string{"https://static.example.com"},
+		AllowOriginsPatterns: []string{"https://*.static.example.com"},
+		AllowOriginsRegex:    []string{`https://regex-[0-9]+\.example\.com`},
+		AllowOriginsFile:     path,
+	}
+	for name, expected := range map[string][]string{
+		OriginValidatorStatic: {"https://static.example.com", "https://a.static.example.com"},
+		OriginValidatorRegex:  {"https://regex-1.example.com"},
+		OriginValidatorFile:   {"https://file.example.com"},
+	} {
+		v, err := NewOriginValidator(name, cfg, nil)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, origin := range []string{
+			"https://static.example.com",
+			"https://a.static.example.com",
+			"https://regex-1.example.com",
+			"https://file.example.com",
+		} {
+			if ok, _ := v.ValidateOrigin(nil, origin); ok != contains(expected, origin) {
+				t.Errorf("unexpected validation of %s by the %s validator: %v", origin, name, ok)
+			}
+		}
+	}
+
+	if _, err := NewOriginValidator(OriginValidatorFile, Config{}, nil); err == nil {
+		t.Error("an error was expected")
+	}
+	if _, err := NewOriginValidator("unknown", cfg, nil); err == nil || err.Error() != `unknown origin validator "unknown"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPolicy_originValidator(t *testing.T) {
+	RegisterOriginValidator("test-tenants", func(cfg Config, _ logging.Logger) (OriginValidator, error) {
+		tenants, _ := cfg.OriginValidatorConfig["tenants"].([]interface{})
+		if len(tenants) == 0 {
+			return nil, errors.New("no tenants")
+		}
+		return OriginValidatorFunc(func(r *http.Request, origin string) (bool, string) {
+			for _, tenant := range tenants {
+				if origin == tenant && r.Header.Get("X-Tenant") != "" {
+					return true, "tenant " + r.Header.Get("X-Tenant")
+				}
+			}
+			return false, "not registered"
+		}), nil
+	})
+	if !contains(OriginValidators(), "test-tenants") {
+		t.Errorf("the validator was not registered: %v", OriginValidators())
+	}
+
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+	p, err := NewPolicy(Config{
+		OriginValidator:       "test-tenants",
+		OriginValidatorConfig: map[string]interface{}{"tenants": []interface{}{"https://a.example.com"}},
+		Debug:                 true,
+	}, l)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, tc := range []struct {
+		origin  string
+		tenant  string
+		allowed bool
+	}{
+		{"https://a.example.com", "a", true},
+		{"https://a.example.com", "", false},
+		{"https://b.example.com", "b", false},
+	} {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", tc.origin)
+		if tc.tenant != "" {
+			req.Header.Add("X-Tenant", tc.tenant)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if allowed := res.Header().Get("Access-Control-Allow-Origin") == tc.origin; allowed != tc.allowed {
+			t.Errorf("unexpected decision for %s (%q): %v", tc.origin, tc.tenant, allowed)
+		}
+	}
+	for _, line := range []string{`rule="tenant a"`, `detail="not registered"`} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("%s not logged: %s", line, buf.String())
+		}
+	}
+
+	if _, err := NewPolicy(Config{OriginValidator: "test-tenants"}, nil); err == nil {
+		t.Error("an error was expected")
+	}
+}
+
+func TestWithOriginValidator(t *testing.T) {
+	p, err := NewPolicy(Config{}, nil, WithOriginValidator(OriginValidatorFunc(func(_ *http.Request, origin string) (bool, string) {
+		return strings.HasSuffix(origin, ".example.com"), ""
+	})))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h := p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for origin, allowed := range map[string]bool{
+		"https://a.example.com": true,
+		"https://example.org":   false,
+	} {
+		req, _ := http.NewRequest("GET", "https://example.com/foo", http.NoBody)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if (res.Header().Get("Access-Control-Allow-Origin") == origin) != allowed {
+			t.Errorf("unexpected decision for %s", origin)
+		}
+	}
+}